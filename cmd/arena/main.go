@@ -0,0 +1,295 @@
+// Command arena plays two engines against each other and reports which is
+// stronger. Games come in pairs from the same random opening with colours
+// swapped, and are played with the same models.Board rules as the server.
+package main
+
+import (
+	"bufio"
+	"connect4/internal/bot"
+	"connect4/internal/models"
+	"connect4/internal/solver"
+	"connect4/pkg/logger"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type result int
+
+const (
+	resultDraw result = iota
+	resultRed
+	resultYellow
+)
+
+type game struct {
+	Number int    `json:"game"`
+	Red    string `json:"red"`
+	Yellow string `json:"yellow"`
+	Moves  string `json:"moves"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+
+	opening []int
+	aIsRed  bool
+	result  result
+}
+
+func main() {
+	specA := flag.String("a", bot.MinimaxEngineName(bot.DifficultyHard), "first engine: minimax-<difficulty>, random, solver or ext:<command>")
+	specB := flag.String("b", bot.MinimaxEngineName(bot.DifficultyMedium), "second engine, same forms as -a")
+	games := flag.Int("games", 100, "number of games; rounded up to an even number")
+	openingPlies := flag.Int("opening", 2, "random moves played before the engines take over")
+	moveTime := flag.Duration("movetime", 100*time.Millisecond, "time per move")
+	workers := flag.Int("workers", runtime.NumCPU(), "games played in parallel")
+	out := flag.String("out", "", "write games as JSON lines to this file")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for openings")
+	bookPath := flag.String("book", "", "solver book for the solver engine, which cannot solve early positions in time without one")
+	flag.Parse()
+
+	switch {
+	case *games <= 0:
+		fail(fmt.Errorf("-games must be at least 1, got %d", *games))
+	case *openingPlies < 0 || *openingPlies >= 42:
+		fail(fmt.Errorf("-opening must be from 0 to 41 moves, got %d", *openingPlies))
+	case *workers <= 0:
+		fail(fmt.Errorf("-workers must be at least 1, got %d", *workers))
+	}
+
+	if err := logger.Init("production"); err != nil {
+		fail(err)
+	}
+	var book *solver.Book
+	if *bookPath != "" {
+		var err error
+		if book, err = solver.LoadBook(*bookPath); err != nil {
+			fail(err)
+		}
+	}
+	// Check both specs before starting any games.
+	for _, spec := range []string{*specA, *specB} {
+		e, err := newEngine(spec, *moveTime, book)
+		if err != nil {
+			fail(err)
+		}
+		closeEngine(e)
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	pairs := (*games + 1) / 2
+	all := make([]*game, 0, 2*pairs)
+	for i := 0; i < pairs; i++ {
+		opening := randomOpening(rng, *openingPlies)
+		all = append(all,
+			&game{Number: 2*i + 1, Red: *specA, Yellow: *specB, opening: opening, aIsRed: true},
+			&game{Number: 2*i + 2, Red: *specB, Yellow: *specA, opening: opening},
+		)
+	}
+
+	jobs := make(chan *game)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker gets its own engines, so solvers and external
+			// processes are not shared between games.
+			a, _ := newEngine(*specA, *moveTime, book)
+			b, _ := newEngine(*specB, *moveTime, book)
+			defer closeEngine(a)
+			defer closeEngine(b)
+			for g := range jobs {
+				if g.aIsRed {
+					play(g, a, b, *moveTime)
+				} else {
+					play(g, b, a, *moveTime)
+				}
+			}
+		}()
+	}
+	start := time.Now()
+	for _, g := range all {
+		jobs <- g
+	}
+	close(jobs)
+	wg.Wait()
+
+	if *out != "" {
+		if err := writeGames(*out, all); err != nil {
+			fail(err)
+		}
+	}
+	report(*specA, *specB, all, time.Since(start))
+}
+
+func newEngine(spec string, moveTime time.Duration, book *solver.Book) (bot.Engine, error) {
+	if command, ok := strings.CutPrefix(spec, "ext:"); ok {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty command in %q", spec)
+		}
+		return bot.NewExternalEngine(spec, fields, moveTime), nil
+	}
+	switch spec {
+	case bot.RandomEngineName:
+		return bot.RandomEngine{}, nil
+	case bot.SolverEngineName:
+		return bot.SolverEngine{Solver: solver.New(book)}, nil
+	}
+	for _, d := range bot.Difficulties() {
+		if spec == bot.MinimaxEngineName(d) {
+			return bot.New(d, moveTime, nil), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown engine %q", spec)
+}
+
+func closeEngine(e bot.Engine) {
+	if ext, ok := e.(*bot.ExternalEngine); ok {
+		ext.Close()
+	}
+}
+
+// randomOpening plays plies random moves, avoiding any that win outright.
+func randomOpening(rng *rand.Rand, plies int) []int {
+	board := models.NewBoard()
+	var moves []int
+	for len(moves) < plies {
+		col := rng.Intn(7)
+		if !board.IsValidMove(col) {
+			continue
+		}
+		row := board.DropDisc(col, len(moves)%2+1)
+		moves = append(moves, col)
+		if board.CheckWin(row, col) {
+			return moves[:len(moves)-1]
+		}
+	}
+	return moves
+}
+
+// play runs one game. An engine that errors or picks an invalid column
+// loses it.
+func play(g *game, red, yellow bot.Engine, moveTime time.Duration) {
+	board := models.NewBoard()
+	moves := make([]int, 0, 42)
+	finish := func(r result, errMsg string) {
+		g.result = r
+		g.Moves = solver.FormatMoves(moves)
+		g.Error = errMsg
+		g.Result = [...]string{"draw", "red", "yellow"}[r]
+	}
+
+	for i, col := range g.opening {
+		board.DropDisc(col, i%2+1)
+		moves = append(moves, col)
+	}
+	for !board.IsFull() {
+		playerNum := len(moves)%2 + 1
+		engine, loss := red, resultYellow
+		if playerNum == 2 {
+			engine, loss = yellow, resultRed
+		}
+
+		// Allow some slack over the engine's own budget before giving up.
+		ctx, cancel := context.WithTimeout(context.Background(), 2*moveTime)
+		move, err := engine.BestMove(ctx, models.BitboardFromBoard(board))
+		cancel()
+		if err != nil {
+			finish(loss, fmt.Sprintf("%s: %v", engine.Name(), err))
+			return
+		}
+		if !board.IsValidMove(move.Column) {
+			finish(loss, fmt.Sprintf("%s: invalid column %d", engine.Name(), move.Column))
+			return
+		}
+		row := board.DropDisc(move.Column, playerNum)
+		moves = append(moves, move.Column)
+		if board.CheckWin(row, move.Column) {
+			finish(result(playerNum), "")
+			return
+		}
+	}
+	finish(resultDraw, "")
+}
+
+func writeGames(path string, games []*game) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, g := range games {
+		if err := enc.Encode(g); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// report prints the match score from a's point of view with the Elo
+// difference and its 95% confidence interval.
+func report(a, b string, games []*game, elapsed time.Duration) {
+	var wins, draws, losses, errors int
+	for _, g := range games {
+		if g.Error != "" {
+			errors++
+		}
+		switch {
+		case g.result == resultDraw:
+			draws++
+		case (g.result == resultRed) == g.aIsRed:
+			wins++
+		default:
+			losses++
+		}
+	}
+	n := float64(len(games))
+	score := (float64(wins) + float64(draws)/2) / n
+	variance := (float64(wins)*math.Pow(1-score, 2) +
+		float64(draws)*math.Pow(0.5-score, 2) +
+		float64(losses)*math.Pow(score, 2)) / n
+	margin := 1.96 * math.Sqrt(variance/n)
+
+	fmt.Printf("%s vs %s: %d games in %s\n", a, b, len(games), elapsed.Round(time.Second))
+	fmt.Printf("  +%d =%d -%d  score %.1f%%\n", wins, draws, losses, 100*score)
+	fmt.Printf("  elo %s  (95%% CI %s to %s)\n", formatElo(elo(score)), formatElo(elo(score-margin)), formatElo(elo(score+margin)))
+	if errors > 0 {
+		fmt.Printf("  %d games lost to engine errors; see -out for details\n", errors)
+	}
+}
+
+func elo(score float64) float64 {
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	if score >= 1 {
+		return math.Inf(1)
+	}
+	return -400 * math.Log10(1/score-1)
+}
+
+func formatElo(e float64) string {
+	if math.IsInf(e, 0) {
+		if e > 0 {
+			return "+inf"
+		}
+		return "-inf"
+	}
+	return fmt.Sprintf("%+.0f", e)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
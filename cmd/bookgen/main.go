@@ -0,0 +1,149 @@
+// Command bookgen solves every position up to a given number of moves and
+// writes the results as an opening book, either for the bot (best column per
+// position) or for the solver (score per position).
+package main
+
+import (
+	"bufio"
+	"connect4/internal/models"
+	"connect4/internal/solver"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+type entry struct {
+	moves  []int
+	board  models.Bitboard
+	col    int
+	result solver.Result
+	err    error
+}
+
+func main() {
+	depth := flag.Int("depth", 4, "include positions with at most this many moves")
+	format := flag.String("format", "bot", "book to write: bot (best column) or solver (score)")
+	out := flag.String("out", "", "output file (default stdout)")
+	seed := flag.String("solver-book", "", "existing solver book to speed up solving")
+	workers := flag.Int("workers", runtime.NumCPU(), "positions solved in parallel")
+	timeout := flag.Duration("timeout", 0, "give up on a position after this long (0 = never)")
+	flag.Parse()
+
+	if *format != "bot" && *format != "solver" {
+		fail(fmt.Errorf("unknown format %q", *format))
+	}
+
+	var book *solver.Book
+	if *seed != "" {
+		var err error
+		if book, err = solver.LoadBook(*seed); err != nil {
+			fail(err)
+		}
+	}
+
+	entries := enumerate(*depth)
+	fmt.Fprintf(os.Stderr, "solving %d positions with %d workers\n", len(entries), *workers)
+
+	jobs := make(chan *entry)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := solver.New(book)
+			for e := range jobs {
+				ctx, cancel := context.Background(), context.CancelFunc(func() {})
+				if *timeout > 0 {
+					ctx, cancel = context.WithTimeout(ctx, *timeout)
+				}
+				e.col, e.result, e.err = s.BestMove(ctx, e.board)
+				cancel()
+			}
+		}()
+	}
+	for _, e := range entries {
+		jobs <- e
+	}
+	close(jobs)
+	wg.Wait()
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := write(w, *format, *depth, entries); err != nil {
+		fail(err)
+	}
+}
+
+// enumerate lists every position reachable in at most depth moves where
+// the game is still going, keeping one of each mirror-image pair.
+func enumerate(depth int) []*entry {
+	var entries []*entry
+	seen := make(map[uint64]bool)
+	var walk func(board models.Bitboard, moves []int)
+	walk = func(board models.Bitboard, moves []int) {
+		mirror := board.Mirror()
+		if seen[board.Key()] || seen[mirror.Key()] {
+			return
+		}
+		seen[board.Key()] = true
+		entries = append(entries, &entry{moves: append([]int(nil), moves...), board: board})
+		if len(moves) == depth {
+			return
+		}
+		player := board.MoveCount()%2 + 1
+		for col := 0; col < 7; col++ {
+			if !board.IsValidMove(col) || board.IsWinningMove(col, player) {
+				continue
+			}
+			next := board
+			next.DropDisc(col, player)
+			if next.IsFull() {
+				continue
+			}
+			walk(next, append(moves, col))
+		}
+	}
+	walk(models.NewBitboard(), nil)
+	return entries
+}
+
+func write(w io.Writer, format string, depth int, entries []*entry) error {
+	bw := bufio.NewWriter(w)
+	if format == "bot" {
+		fmt.Fprintf(bw, "# bot book: <moves> <column>, positions up to %d moves\n", depth)
+	} else {
+		fmt.Fprintf(bw, "# solver book: <moves> <score>, positions up to %d moves\n", depth)
+	}
+	skipped := 0
+	for _, e := range entries {
+		if e.err != nil {
+			skipped++
+			continue
+		}
+		if format == "bot" {
+			fmt.Fprintf(bw, "%s %d\n", solver.FormatMoves(e.moves), e.col+1)
+		} else {
+			fmt.Fprintf(bw, "%s %d\n", solver.FormatMoves(e.moves), e.result.Score)
+		}
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d positions that timed out\n", skipped)
+	}
+	return bw.Flush()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"connect4/internal/bot"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func main() {
+	depth := flag.Int("depth", 8, "search depth")
+	difficulty := flag.String("difficulty", string(bot.DifficultyHard), "evaluation profile to search with")
+	flag.Parse()
+
+	d, err := bot.ParseDifficulty(*difficulty)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	b := bot.New(d, 0, nil)
+
+	variants := []struct {
+		name string
+		opts bot.SearchOptions
+	}{
+		{"plain", bot.SearchOptions{DisableTable: true, DisableOrdering: true}},
+		{"ordering", bot.SearchOptions{DisableTable: true}},
+		{"table", bot.SearchOptions{DisableOrdering: true}},
+		{"table+ordering", bot.SearchOptions{}},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "position\tvariant\tdepth\tmove\tnodes\ttt hits\ttime\t")
+	for _, p := range bot.BenchPositions {
+		board := p.Board()
+		for _, v := range variants {
+			stats := b.Analyze(board, *depth, v.opts)
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n", p.Name, v.name, stats.Depth, stats.Move, stats.Nodes, stats.TableHits, stats.Elapsed.Round(1000))
+		}
+	}
+	w.Flush()
+}
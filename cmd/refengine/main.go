@@ -0,0 +1,150 @@
+// Command refengine is a reference external engine: it speaks the bot
+// protocol on stdin and stdout and plays with the built-in minimax bot. It
+// exists to exercise bot.ExternalEngine and as a template for engines
+// written in other languages.
+package main
+
+import (
+	"bufio"
+	"connect4/internal/bot"
+	"connect4/internal/models"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type engine struct {
+	bot      *bot.Bot
+	out      *bufio.Writer
+	outMutex sync.Mutex
+	position models.Bitboard
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func main() {
+	difficulty := flag.String("difficulty", string(bot.DifficultyHard), "minimax profile to play with")
+	flag.Parse()
+
+	d, err := bot.ParseDifficulty(*difficulty)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	e := &engine{
+		bot:      bot.New(d, 0, nil),
+		out:      bufio.NewWriter(os.Stdout),
+		position: models.NewBitboard(),
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "c4i":
+			e.send("id name refengine-" + string(d))
+			e.send("c4iok")
+		case "isready":
+			e.wait()
+			e.send("readyok")
+		case "position":
+			e.wait()
+			if len(fields) < 2 {
+				continue
+			}
+			position, err := bot.ParsePosition(fields[1])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "refengine:", err)
+				continue
+			}
+			e.position = position
+		case "go":
+			e.wait()
+			e.start(parseMoveTime(fields))
+		case "stop":
+			if e.cancel != nil {
+				e.cancel()
+			}
+			e.wait()
+		case "quit":
+			if e.cancel != nil {
+				e.cancel()
+			}
+			e.wait()
+			return
+		}
+	}
+	e.wait()
+}
+
+// parseMoveTime reads "go movetime <ms>"; without it the search runs until
+// stopped or the bot reaches its profile depth.
+func parseMoveTime(fields []string) time.Duration {
+	for i := 1; i+1 < len(fields); i++ {
+		if fields[i] == "movetime" {
+			if ms, err := strconv.Atoi(fields[i+1]); err == nil {
+				return time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return 0
+}
+
+func (e *engine) start(moveTime time.Duration) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if moveTime > 0 {
+		ctx, cancel = context.WithTimeout(ctx, moveTime)
+	}
+	ctx, stop := context.WithCancel(ctx)
+	e.cancel = func() {
+		stop()
+		cancel()
+	}
+	e.done = make(chan struct{})
+	position := e.position
+
+	go func() {
+		defer close(e.done)
+		move, err := e.bot.BestMove(ctx, position)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "refengine:", err)
+			e.send("bestmove none")
+			return
+		}
+		if move.Score != nil {
+			pv := make([]string, len(move.PV))
+			for i, col := range move.PV {
+				pv[i] = strconv.Itoa(col + 1)
+			}
+			e.send(fmt.Sprintf("info score %g pv %s", *move.Score, strings.Join(pv, " ")))
+		}
+		e.send(fmt.Sprintf("bestmove %d", move.Column+1))
+	}()
+}
+
+// wait blocks until the running search, if any, has replied.
+func (e *engine) wait() {
+	if e.done != nil {
+		<-e.done
+		e.done = nil
+	}
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+}
+
+func (e *engine) send(line string) {
+	e.outMutex.Lock()
+	defer e.outMutex.Unlock()
+	fmt.Fprintln(e.out, line)
+	e.out.Flush()
+}
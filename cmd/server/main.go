@@ -1,16 +1,19 @@
 package main
 
 import (
+	"connect4/internal/bot"
 	"connect4/internal/config"
 	"connect4/internal/database"
 	"connect4/internal/handlers"
 	"connect4/internal/middleware"
 	"connect4/internal/services"
+	"connect4/internal/solver"
 	"connect4/pkg/logger"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -43,16 +46,56 @@ func main() {
 	}
 	defer db.Close()
 
+	// Load the solver's opening book, if one is configured
+	var book *solver.Book
+	if cfg.Game.SolverBookPath != "" {
+		book, err = solver.LoadBook(cfg.Game.SolverBookPath)
+		if err != nil {
+			logger.Log.Fatal("Failed to load solver book", zap.Error(err))
+		}
+		logger.Log.Info("Solver book loaded", zap.Int("positions", book.Len()))
+	}
+
+	// Load the bot's opening book, if one is configured
+	var botBook *bot.Book
+	if cfg.Game.BotBookPath != "" {
+		botBook, err = bot.LoadBook(cfg.Game.BotBookPath)
+		if err != nil {
+			logger.Log.Fatal("Failed to load bot book", zap.Error(err))
+		}
+		logger.Log.Info("Bot book loaded", zap.Int("positions", botBook.Len()))
+	}
+
 	// Initialize services
-	gameService := services.NewGameService(db)
+	gameService := services.NewGameService(db, cfg, solver.New(book), botBook)
+	budget := time.Duration(cfg.Game.BotMoveBudgetMs) * time.Millisecond
+	var externalEngines []*bot.ExternalEngine
+	for _, ec := range cfg.Game.ExternalEngines {
+		engine := bot.NewExternalEngine(ec.Name, ec.Command, budget)
+		if err := gameService.RegisterEngine(engine); err != nil {
+			logger.Log.Fatal("Failed to register external engine", zap.Error(err))
+		}
+		externalEngines = append(externalEngines, engine)
+	}
+	logger.Log.Info("Bot engines registered", zap.Strings("engines", gameService.EngineNames()))
 	matchmakingService := services.NewMatchmakingService(db, cfg, gameService)
 	reconnectionService := services.NewReconnectionService(cfg, gameService)
-	leaderboardService := services.NewLeaderboardService(db)
+	leaderboardService := services.NewLeaderboardService(db, cfg, gameService)
+	roomService := services.NewRoomService(db, cfg, gameService)
+	challengeService := services.NewChallengeService(db, cfg, gameService, matchmakingService, roomService)
+	tournamentService := services.NewTournamentService(db, gameService)
+	chatService := services.NewChatService(db, cfg, gameService)
+	replayService := services.NewReplayService(db)
+	authService := services.NewAuthService(db, cfg)
 
 	// Initialize handlers
-	wsHandler := handlers.NewWSHandler(matchmakingService, gameService, reconnectionService)
+	wsHandler := handlers.NewWSHandler(matchmakingService, gameService, reconnectionService, roomService, challengeService, tournamentService, chatService, authService)
 	httpHandler := handlers.NewHTTPHandler(leaderboardService)
-	gameHandler := handlers.NewGameHandler(db)
+	roomHandler := handlers.NewRoomHandler(roomService, matchmakingService)
+	tournamentHandler := handlers.NewTournamentHandler(tournamentService)
+	gameHandler := handlers.NewGameHandler(db, gameService)
+	replayHandler := handlers.NewReplayHandler(replayService)
+	authHandler := handlers.NewAuthHandler(authService)
 
 	// Setup Gin
 	if cfg.Server.Env == "production" {
@@ -62,7 +105,7 @@ func main() {
 	r := gin.New()
 	
 	// Middleware
-	r.Use(gin.Logger())
+	r.Use(middleware.Logger())
 	r.Use(middleware.Recovery())
 	r.Use(middleware.CORS())
 	r.Use(middleware.ErrorHandler())
@@ -72,11 +115,31 @@ func main() {
 	r.GET("/ws", wsHandler.HandleWebSocket)
 
 	// API Routes
+	requireAuth := middleware.RequireAuth(authService)
 	api := r.Group("/api")
 	{
 		api.GET("/health", gameHandler.GetHealth)
+		api.POST("/auth/register", authHandler.Register)
+		api.POST("/auth/login", authHandler.Login)
+		api.POST("/auth/guest", authHandler.Guest)
+		api.POST("/auth/logout", authHandler.Logout)
+		api.GET("/auth/me", requireAuth, authHandler.Me)
 		api.GET("/leaderboard", httpHandler.GetLeaderboard)
+		api.GET("/games/live", gameHandler.GetLiveGames)
+		api.GET("/games/:id", replayHandler.GetGame)
+		api.GET("/games/:id/positions", replayHandler.GetPosition)
 		api.GET("/player/:username", httpHandler.GetPlayerStats)
+		api.GET("/player/:username/ratings", httpHandler.GetRatingHistory)
+		api.GET("/player/:username/games", httpHandler.GetGameHistory)
+		api.GET("/players/:a/vs/:b", httpHandler.GetHeadToHead)
+		api.POST("/rooms", requireAuth, roomHandler.CreateRoom)
+		api.GET("/rooms/:code", roomHandler.GetRoom)
+		api.POST("/rooms/:code/join", requireAuth, roomHandler.JoinRoom)
+		api.GET("/tournaments", tournamentHandler.ListTournaments)
+		api.POST("/tournaments", requireAuth, tournamentHandler.CreateTournament)
+		api.GET("/tournaments/:id", tournamentHandler.GetTournament)
+		api.POST("/tournaments/:id/join", requireAuth, tournamentHandler.JoinTournament)
+		api.POST("/tournaments/:id/start", requireAuth, tournamentHandler.StartTournament)
 	}
 
 	// Start server
@@ -94,4 +157,7 @@ func main() {
 	<-quit
 
 	logger.Log.Info("Shutting down server...")
+	for _, engine := range externalEngines {
+		engine.Close()
+	}
 }
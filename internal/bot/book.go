@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"connect4/internal/models"
+	"connect4/internal/solver"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Book maps early positions to the column the bot should play in them.
+//
+// The file format is plain text, one position per line:
+//
+//	<moves> <column>
+//
+// where moves is the sequence of columns played from the empty board,
+// numbered 1-7 from the left, or "-" for the empty board, and column is the
+// best reply, also 1-7. Blank lines and lines starting with '#' are ignored.
+// A position also answers for its mirror image. cmd/bookgen writes this
+// format from solver output.
+type Book struct {
+	moves map[uint64]int8
+}
+
+func NewBook() *Book {
+	return &Book{moves: make(map[uint64]int8)}
+}
+
+func LoadBook(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open book: %w", err)
+	}
+	defer f.Close()
+	return ReadBook(f)
+}
+
+func ReadBook(r io.Reader) (*Book, error) {
+	b := NewBook()
+	err := solver.ScanBook(r, func(moves string, col int) error {
+		if col < 1 || col > 7 {
+			return fmt.Errorf("invalid column %d", col)
+		}
+		return b.Add(moves, col-1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Add records col (0-based) as the best move after moves.
+func (b *Book) Add(moves string, col int) error {
+	board, err := solver.ParseMoves(moves)
+	if err != nil {
+		return err
+	}
+	if !board.IsValidMove(col) {
+		return fmt.Errorf("column %d is not playable", col+1)
+	}
+	b.moves[board.Key()] = int8(col)
+	return nil
+}
+
+func (b *Book) Len() int {
+	return len(b.moves)
+}
+
+// Move returns the book column for board, if there is one.
+func (b *Book) Move(board *models.Bitboard) (int, bool) {
+	if b == nil {
+		return -1, false
+	}
+	if col, ok := b.moves[board.Key()]; ok {
+		return int(col), true
+	}
+	mirror := board.Mirror()
+	if col, ok := b.moves[mirror.Key()]; ok {
+		return 6 - int(col), true
+	}
+	return -1, false
+}
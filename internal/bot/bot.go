@@ -2,171 +2,206 @@ package bot
 
 import (
 	"connect4/internal/models"
-	"math"
+	"connect4/internal/solver"
+	"context"
+	"errors"
+	"math/bits"
+	"math/rand"
+	"time"
 )
 
-const (
-	maxDepth = 5
-	botNum   = 2
-	humanNum = 1
-)
+// winScore is what the search scores a forced win at the horizon; quicker
+// wins score a little higher.
+const winScore = 1000.0
 
-type Bot struct{}
+var errNoMoves = errors.New("no valid moves")
 
-func New() *Bot {
-	return &Bot{}
+type Bot struct {
+	difficulty Difficulty
+	profile    Profile
+	budget     time.Duration
+	book       *Book
+	solver     *solver.Solver
 }
 
-func (b *Bot) GetBestMove(board models.Board) int {
-	if col := b.findWinningMove(board, botNum); col != -1 {
-		return col
+// New returns a bot for the given difficulty. A positive budget bounds how
+// long a move may take; zero searches to the profile depth. book may be nil,
+// and is ignored by profiles that do not use one.
+func New(difficulty Difficulty, budget time.Duration, book *Book) *Bot {
+	return &Bot{
+		difficulty: difficulty,
+		profile:    ProfileFor(difficulty),
+		budget:     budget,
+		book:       book,
 	}
-	if col := b.findWinningMove(board, humanNum); col != -1 {
-		return col
+}
+
+// SetSolver gives the bot a solver to use if its profile asks for one.
+func (b *Bot) SetSolver(s *solver.Solver) {
+	b.solver = s
+}
+
+func (b *Bot) Difficulty() Difficulty {
+	return b.difficulty
+}
+
+func (b *Bot) Name() string {
+	return MinimaxEngineName(b.difficulty)
+}
+
+// GetBestMove returns the column the bot plays for the side to move, or -1
+// if the board is full.
+func (b *Bot) GetBestMove(board models.Board) int {
+	move, err := b.BestMove(context.Background(), models.BitboardFromBoard(board))
+	if err != nil {
+		return -1
 	}
+	return move.Column
+}
 
-	bestScore := math.Inf(-1)
-	bestCol := -1
+// BestMove implements Engine. The search stops at the bot's budget or the
+// context deadline, whichever comes first; a cancelled context still gets
+// the best move from a one-ply search.
+func (b *Bot) BestMove(ctx context.Context, board models.Bitboard) (Move, error) {
+	me := board.MoveCount()%2 + 1
+	if b.profile.MistakeRate > 0 && rand.Float64() < b.profile.MistakeRate {
+		if col := randomMove(&board); col != -1 {
+			return Move{Column: col}, nil
+		}
+	}
+	if col := findWinningMove(&board, me); col != -1 {
+		score := winScore
+		return Move{Column: col, Score: &score, PV: []int{col}}, nil
+	}
+	if col := findWinningMove(&board, 3-me); col != -1 {
+		return Move{Column: col}, nil
+	}
 
-	for col := 0; col < 7; col++ {
-		if !board.IsValidMove(col) {
-			continue
+	if b.profile.UseBook {
+		if col, ok := b.book.Move(&board); ok {
+			return Move{Column: col}, nil
 		}
-		boardCopy := board.Copy()
-		boardCopy.DropDisc(col, botNum)
-		score := b.minimax(boardCopy, maxDepth-1, math.Inf(-1), math.Inf(1), false)
-		if col == 3 {
-			score += 0.1
+	}
+
+	var deadline time.Time
+	if b.budget > 0 {
+		deadline = time.Now().Add(b.budget)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if b.profile.Solve && b.solver != nil {
+		// Leave half the time for the fallback search.
+		solveCtx, cancel := ctx, context.CancelFunc(func() {})
+		if !deadline.IsZero() {
+			solveCtx, cancel = context.WithDeadline(ctx, time.Now().Add(time.Until(deadline)/2))
 		}
-		if score > bestScore {
-			bestScore = score
-			bestCol = col
+		col, result, err := b.solver.BestMove(solveCtx, board)
+		cancel()
+		if err == nil {
+			score := float64(result.Score)
+			return Move{Column: col, Score: &score}, nil
 		}
 	}
 
+	bestCol, stats := b.iterate(ctx, board, b.profile.Depth, deadline, SearchOptions{})
 	if bestCol == -1 {
-		if board.IsValidMove(3) {
-			return 3
-		}
-		for col := 0; col < 7; col++ {
-			if board.IsValidMove(col) {
-				return col
-			}
-		}
+		return Move{}, errNoMoves
 	}
-	return bestCol
+	return Move{Column: bestCol, Score: &stats.Score, PV: stats.PV}, nil
 }
 
-func (b *Bot) findWinningMove(board models.Board, playerNum int) int {
+func randomMove(board *models.Bitboard) int {
+	valid := make([]int, 0, 7)
 	for col := 0; col < 7; col++ {
-		if !board.IsValidMove(col) {
-			continue
+		if board.IsValidMove(col) {
+			valid = append(valid, col)
 		}
-		boardCopy := board.Copy()
-		row := boardCopy.DropDisc(col, playerNum)
-		if boardCopy.CheckWin(row, col) {
+	}
+	if len(valid) == 0 {
+		return -1
+	}
+	return valid[rand.Intn(len(valid))]
+}
+
+func findWinningMove(board *models.Bitboard, playerNum int) int {
+	for col := 0; col < 7; col++ {
+		if board.IsWinningMove(col, playerNum) {
 			return col
 		}
 	}
 	return -1
 }
 
-func (b *Bot) minimax(board models.Board, depth int, alpha, beta float64, isMaximizing bool) float64 {
-	if depth == 0 || board.IsFull() {
-		return b.evaluateBoard(board)
-	}
-
-	if isMaximizing {
-		maxEval := math.Inf(-1)
-		for col := 0; col < 7; col++ {
-			if !board.IsValidMove(col) {
-				continue
-			}
-			boardCopy := board.Copy()
-			row := boardCopy.DropDisc(col, botNum)
-			if boardCopy.CheckWin(row, col) {
-				return 1000.0 + float64(depth)
-			}
-			eval := b.minimax(boardCopy, depth-1, alpha, beta, false)
-			maxEval = math.Max(maxEval, eval)
-			alpha = math.Max(alpha, eval)
-			if beta <= alpha {
-				break
-			}
-		}
-		return maxEval
-	} else {
-		minEval := math.Inf(1)
-		for col := 0; col < 7; col++ {
-			if !board.IsValidMove(col) {
-				continue
-			}
-			boardCopy := board.Copy()
-			row := boardCopy.DropDisc(col, humanNum)
-			if boardCopy.CheckWin(row, col) {
-				return -1000.0 - float64(depth)
-			}
-			eval := b.minimax(boardCopy, depth-1, alpha, beta, true)
-			minEval = math.Min(minEval, eval)
-			beta = math.Min(beta, eval)
-			if beta <= alpha {
-				break
-			}
+// windows holds a mask for every horizontal, vertical and diagonal line of
+// four cells on the board.
+var windows = func() []uint64 {
+	var ws []uint64
+	add := func(row, col, dRow, dCol int) {
+		var m uint64
+		for i := 0; i < 4; i++ {
+			m |= models.CellMask(row+i*dRow, col+i*dCol)
 		}
-		return minEval
+		ws = append(ws, m)
 	}
-}
-
-func (b *Bot) evaluateBoard(board models.Board) float64 {
-	score := 0.0
 	for row := 0; row < 6; row++ {
 		for col := 0; col < 4; col++ {
-			window := []int{board[row][col], board[row][col+1], board[row][col+2], board[row][col+3]}
-			score += b.evaluateWindow(window)
+			add(row, col, 0, 1)
 		}
 	}
 	for col := 0; col < 7; col++ {
 		for row := 0; row < 3; row++ {
-			window := []int{board[row][col], board[row+1][col], board[row+2][col], board[row+3][col]}
-			score += b.evaluateWindow(window)
+			add(row, col, 1, 0)
 		}
 	}
 	for row := 3; row < 6; row++ {
 		for col := 0; col < 4; col++ {
-			window := []int{board[row][col], board[row-1][col+1], board[row-2][col+2], board[row-3][col+3]}
-			score += b.evaluateWindow(window)
+			add(row, col, -1, 1)
 		}
 	}
 	for row := 0; row < 3; row++ {
 		for col := 0; col < 4; col++ {
-			window := []int{board[row][col], board[row+1][col+1], board[row+2][col+2], board[row+3][col+3]}
-			score += b.evaluateWindow(window)
+			add(row, col, 1, 1)
 		}
 	}
+	return ws
+}()
+
+var centerColumn = func() uint64 {
+	var m uint64
+	for row := 0; row < 6; row++ {
+		m |= models.CellMask(row, 3)
+	}
+	return m
+}()
+
+// evaluateBoard scores the position from player me's point of view.
+func (b *Bot) evaluateBoard(board *models.Bitboard, me int) float64 {
+	own, opponent := board.Discs(me), board.Discs(3-me)
+	score := 0.0
+	for _, w := range windows {
+		score += b.evaluateWindow(bits.OnesCount64(own&w), bits.OnesCount64(opponent&w))
+	}
+	score += b.profile.Weights.Center * float64(bits.OnesCount64(own&centerColumn))
+	score -= b.profile.Weights.Center * float64(bits.OnesCount64(opponent&centerColumn))
 	return score
 }
 
-func (b *Bot) evaluateWindow(window []int) float64 {
+func (b *Bot) evaluateWindow(ownCount, opponentCount int) float64 {
 	score := 0.0
-	botCount, humanCount, emptyCount := 0, 0, 0
-	for _, cell := range window {
-		if cell == botNum {
-			botCount++
-		} else if cell == humanNum {
-			humanCount++
-		} else {
-			emptyCount++
-		}
+	emptyCount := 4 - ownCount - opponentCount
+	w := b.profile.Weights
+	if ownCount == 4 {
+		score += w.Four
+	} else if ownCount == 3 && emptyCount == 1 {
+		score += w.Three
+	} else if ownCount == 2 && emptyCount == 2 {
+		score += w.Two
 	}
-	if botCount == 4 {
-		score += 100
-	} else if botCount == 3 && emptyCount == 1 {
-		score += 10
-	} else if botCount == 2 && emptyCount == 2 {
-		score += 5
-	}
-	if humanCount == 3 && emptyCount == 1 {
-		score -= 80
+	if opponentCount == 3 && emptyCount == 1 {
+		score += w.OpponentThree
+	} else if opponentCount == 2 && emptyCount == 2 {
+		score += w.OpponentTwo
 	}
 	return score
-}
\ No newline at end of file
+}
@@ -0,0 +1,89 @@
+package bot
+
+import "fmt"
+
+type Difficulty string
+
+const (
+	DifficultyEasy    Difficulty = "easy"
+	DifficultyMedium  Difficulty = "medium"
+	DifficultyHard    Difficulty = "hard"
+	DifficultyPerfect Difficulty = "perfect"
+
+	DefaultDifficulty = DifficultyMedium
+)
+
+// Weights are the scores evaluateWindow assigns to a four-cell window.
+type Weights struct {
+	Four          float64
+	Three         float64
+	Two           float64
+	OpponentThree float64
+	OpponentTwo   float64
+	Center        float64
+}
+
+// Profile controls how hard a bot plays: how deep it searches, how it scores
+// positions and how often it deliberately plays a random move instead.
+// Rating is the fixed rating players are rated against when they play the
+// bot. A profile with UseBook set plays opening book moves when it has a
+// book, and one with Solve set asks the solver first and only searches if
+// the solver runs out of time.
+type Profile struct {
+	Depth       int
+	Weights     Weights
+	MistakeRate float64
+	Rating      float64
+	UseBook     bool
+	Solve       bool
+}
+
+var profiles = map[Difficulty]Profile{
+	DifficultyEasy: {
+		Depth:       2,
+		Weights:     Weights{Four: 100, Three: 5, Two: 2, OpponentThree: -20},
+		MistakeRate: 0.3,
+		Rating:      1000,
+	},
+	DifficultyMedium: {
+		Depth:       5,
+		Weights:     Weights{Four: 100, Three: 10, Two: 5, OpponentThree: -80},
+		MistakeRate: 0.05,
+		Rating:      1400,
+	},
+	DifficultyHard: {
+		Depth:   7,
+		Weights: Weights{Four: 100, Three: 10, Two: 5, OpponentThree: -80, OpponentTwo: -4, Center: 3},
+		Rating:  1800,
+		UseBook: true,
+	},
+	DifficultyPerfect: {
+		Depth:   10,
+		Weights: Weights{Four: 100, Three: 10, Two: 5, OpponentThree: -80, OpponentTwo: -4, Center: 3},
+		Rating:  2200,
+		UseBook: true,
+		Solve:   true,
+	},
+}
+
+func ParseDifficulty(s string) (Difficulty, error) {
+	if s == "" {
+		return DefaultDifficulty, nil
+	}
+	d := Difficulty(s)
+	if _, ok := profiles[d]; !ok {
+		return "", fmt.Errorf("unknown bot difficulty %q", s)
+	}
+	return d, nil
+}
+
+func Difficulties() []Difficulty {
+	return []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard, DifficultyPerfect}
+}
+
+func ProfileFor(d Difficulty) Profile {
+	if p, ok := profiles[d]; ok {
+		return p
+	}
+	return profiles[DefaultDifficulty]
+}
@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"connect4/internal/models"
+	"connect4/internal/solver"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	RandomEngineName = "random"
+	SolverEngineName = "solver"
+)
+
+// Move is an engine's choice of column for the side to move. Score is nil
+// when the engine does not evaluate positions; otherwise higher is better
+// for the side to move, on a scale that is only comparable within one
+// engine. PV, when present, is the expected line starting with Column.
+type Move struct {
+	Column int      `json:"column"`
+	Score  *float64 `json:"score,omitempty"`
+	PV     []int    `json:"pv,omitempty"`
+}
+
+// Engine picks moves. The side to move follows from the move count, since
+// red always moves first. Engines should return promptly once ctx is done,
+// with either their best move so far or ctx's error.
+type Engine interface {
+	Name() string
+	BestMove(ctx context.Context, board models.Bitboard) (Move, error)
+}
+
+func MinimaxEngineName(d Difficulty) string {
+	return "minimax-" + string(d)
+}
+
+// Registry holds engines by name. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	engines map[string]Engine
+}
+
+func NewRegistry() *Registry {
+	return &Registry{engines: make(map[string]Engine)}
+}
+
+func (r *Registry) Register(e Engine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.engines[e.Name()]; exists {
+		return fmt.Errorf("engine %q already registered", e.Name())
+	}
+	r.engines[e.Name()] = e
+	return nil
+}
+
+func (r *Registry) Get(name string) (Engine, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.engines[name]
+	return e, ok
+}
+
+// Names returns the registered engine names in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.engines))
+	for name := range r.engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RandomEngine plays a uniformly random valid column. It is meant for
+// testing.
+type RandomEngine struct{}
+
+func (RandomEngine) Name() string {
+	return RandomEngineName
+}
+
+func (RandomEngine) BestMove(ctx context.Context, board models.Bitboard) (Move, error) {
+	col := randomMove(&board)
+	if col == -1 {
+		return Move{}, errNoMoves
+	}
+	return Move{Column: col}, nil
+}
+
+// SolverEngine plays perfectly using the solver. Early positions can take
+// far longer to solve than a move budget allows, so it is best given an
+// opening book.
+type SolverEngine struct {
+	Solver *solver.Solver
+}
+
+func (e SolverEngine) Name() string {
+	return SolverEngineName
+}
+
+func (e SolverEngine) BestMove(ctx context.Context, board models.Bitboard) (Move, error) {
+	col, result, err := e.Solver.BestMove(ctx, board)
+	if err != nil {
+		return Move{}, err
+	}
+	score := float64(result.Score)
+	return Move{Column: col, Score: &score}, nil
+}
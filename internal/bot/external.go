@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"bufio"
+	"connect4/internal/models"
+	"connect4/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// handshakeTimeout bounds how long a freshly started engine may take to
+	// answer c4i and isready.
+	handshakeTimeout = 5 * time.Second
+	// stopGrace is how long an engine has to answer "stop" before it is
+	// killed and restarted.
+	stopGrace = 200 * time.Millisecond
+	// moveTimeMargin is kept back from the deadline for the reply to arrive.
+	moveTimeMargin = 20 * time.Millisecond
+)
+
+var errEngineExited = errors.New("engine process exited")
+
+// ExternalEngine runs a separate executable that speaks the protocol
+// described in protocol.go. The process is started on first use and
+// restarted on the next move after it crashes, stops responding or sends
+// garbage. Searches run one at a time.
+type ExternalEngine struct {
+	name     string
+	command  []string
+	moveTime time.Duration
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+}
+
+// NewExternalEngine returns an engine that runs command (program and
+// arguments) and lets it think for up to moveTime per move, or less if the
+// caller's context has an earlier deadline.
+func NewExternalEngine(name string, command []string, moveTime time.Duration) *ExternalEngine {
+	return &ExternalEngine{name: name, command: command, moveTime: moveTime}
+}
+
+func (e *ExternalEngine) Name() string {
+	return e.name
+}
+
+func (e *ExternalEngine) BestMove(ctx context.Context, board models.Bitboard) (Move, error) {
+	if board.IsFull() {
+		return Move{}, errNoMoves
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			return Move{}, err
+		}
+	}
+	move, err := e.search(ctx, &board)
+	if err != nil {
+		logger.Log.Warn("External engine failed, restarting", zap.String("engine", e.name), zap.Error(err))
+		e.kill()
+		return Move{}, err
+	}
+	return move, nil
+}
+
+// Close asks the engine process to exit and kills it if it does not.
+func (e *ExternalEngine) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cmd == nil {
+		return
+	}
+	_ = e.send(protocolQuit)
+	select {
+	case <-e.drain():
+	case <-time.After(stopGrace):
+	}
+	e.kill()
+}
+
+func (e *ExternalEngine) start() error {
+	if len(e.command) == 0 {
+		return fmt.Errorf("engine %q has no command", e.name)
+	}
+	cmd := exec.Command(e.command[0], e.command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open engine stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open engine stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start engine %q: %w", e.name, err)
+	}
+
+	lines := make(chan string, 64)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- strings.TrimSpace(scanner.Text())
+		}
+		close(lines)
+	}()
+	e.cmd, e.stdin, e.lines = cmd, stdin, lines
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	for _, step := range [][2]string{{protocolHello, protocolHelloOK}, {protocolIsReady, protocolReadyOK}} {
+		if err := e.send(step[0]); err != nil {
+			e.kill()
+			return err
+		}
+		if _, err := e.await(ctx, step[1]); err != nil {
+			e.kill()
+			return fmt.Errorf("engine %q handshake: %w", e.name, err)
+		}
+	}
+	logger.Log.Info("External engine started", zap.String("engine", e.name), zap.Int("pid", cmd.Process.Pid))
+	return nil
+}
+
+func (e *ExternalEngine) search(ctx context.Context, board *models.Bitboard) (Move, error) {
+	moveTime := e.moveTime
+	if d, ok := ctx.Deadline(); ok {
+		if left := time.Until(d) - moveTimeMargin; left < moveTime || moveTime <= 0 {
+			moveTime = left
+		}
+	}
+	if moveTime < time.Millisecond {
+		moveTime = time.Millisecond
+	}
+
+	if err := e.send(protocolPosition + " " + FormatPosition(board)); err != nil {
+		return Move{}, err
+	}
+	if err := e.send(fmt.Sprintf("%s movetime %d", protocolGo, moveTime.Milliseconds())); err != nil {
+		return Move{}, err
+	}
+
+	var move Move
+	line, err := e.collect(ctx, &move)
+	if err != nil && ctx.Err() != nil {
+		// Out of time: ask for an answer now and give it a moment.
+		if err := e.send(protocolStop); err != nil {
+			return Move{}, err
+		}
+		graceCtx, cancel := context.WithTimeout(context.Background(), stopGrace)
+		defer cancel()
+		line, err = e.collect(graceCtx, &move)
+	}
+	if err != nil {
+		return Move{}, err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Move{}, fmt.Errorf("malformed reply %q", line)
+	}
+	if fields[1] == protocolNoMove {
+		return Move{}, errors.New("engine has no move")
+	}
+	col, err := strconv.Atoi(fields[1])
+	if err != nil || !board.IsValidMove(col-1) {
+		return Move{}, fmt.Errorf("engine chose invalid column %q", fields[1])
+	}
+	move.Column = col - 1
+	if len(move.PV) > 0 && move.PV[0] != move.Column {
+		move.PV = nil
+	}
+	return move, nil
+}
+
+// collect reads info lines into move until the bestmove line, which it
+// returns.
+func (e *ExternalEngine) collect(ctx context.Context, move *Move) (string, error) {
+	for {
+		line, err := e.await(ctx, protocolInfo, protocolBestMove)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(line, protocolBestMove) {
+			return line, nil
+		}
+		parseInfo(line, move)
+	}
+}
+
+// parseInfo reads "info score <s> pv <c1> <c2> ...", ignoring anything it
+// does not understand.
+func parseInfo(line string, move *Move) {
+	fields := strings.Fields(line)
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "score":
+			if i+1 < len(fields) {
+				if score, err := strconv.ParseFloat(fields[i+1], 64); err == nil {
+					move.Score = &score
+				}
+				i++
+			}
+		case "pv":
+			var pv []int
+			for _, f := range fields[i+1:] {
+				col, err := strconv.Atoi(f)
+				if err != nil || col < 1 || col > 7 {
+					break
+				}
+				pv = append(pv, col-1)
+			}
+			move.PV = pv
+			return
+		}
+	}
+}
+
+// await returns the next line starting with one of prefixes, skipping any
+// others.
+func (e *ExternalEngine) await(ctx context.Context, prefixes ...string) (string, error) {
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return "", errEngineExited
+			}
+			for _, p := range prefixes {
+				if line == p || strings.HasPrefix(line, p+" ") {
+					return line, nil
+				}
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (e *ExternalEngine) send(line string) error {
+	if _, err := io.WriteString(e.stdin, line+"\n"); err != nil {
+		return fmt.Errorf("failed to write to engine: %w", err)
+	}
+	return nil
+}
+
+// drain returns a channel that closes once the engine's stdout does.
+func (e *ExternalEngine) drain() <-chan struct{} {
+	done := make(chan struct{})
+	lines := e.lines
+	go func() {
+		for range lines {
+		}
+		close(done)
+	}()
+	return done
+}
+
+func (e *ExternalEngine) kill() {
+	if e.cmd == nil {
+		return
+	}
+	_ = e.stdin.Close()
+	_ = e.cmd.Process.Kill()
+	// Keep the reader from blocking on a full channel so it can exit.
+	go func(lines chan string) {
+		for range lines {
+		}
+	}(e.lines)
+	_ = e.cmd.Wait()
+	e.cmd, e.stdin, e.lines = nil, nil, nil
+}
@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"connect4/internal/models"
+	"fmt"
+	"strings"
+)
+
+// External engines speak a line-based text protocol over stdin and stdout,
+// modelled on chess's UCI. Each command and reply is one line; unknown lines
+// are ignored in both directions.
+//
+// Server to engine:
+//
+//	c4i                  start of session; the engine replies "c4iok",
+//	                     optionally preceded by "id name <name>"
+//	isready              the engine replies "readyok" once idle
+//	position <board>     set the position to search
+//	go movetime <ms>     search the position for at most ms milliseconds
+//	stop                 finish the current search and reply now
+//	quit                 exit
+//
+// Engine to server:
+//
+//	info score <s> pv <c1> <c2> ...   optional progress, columns 1-7
+//	bestmove <col>                    the chosen column, 1-7
+//	bestmove none                     no move: the board is full or the
+//	                                  search failed
+//
+// A board is six rows from top to bottom separated by '/', each of seven
+// cells from left to right: '.' empty, 'r' red, 'y' yellow. Red moves first,
+// so the side to move follows from the disc counts. For example, after red
+// plays the centre column:
+//
+//	position ......./......./......./......./......./...r...
+const (
+	protocolHello    = "c4i"
+	protocolHelloOK  = "c4iok"
+	protocolIsReady  = "isready"
+	protocolReadyOK  = "readyok"
+	protocolPosition = "position"
+	protocolGo       = "go"
+	protocolStop     = "stop"
+	protocolQuit     = "quit"
+	protocolInfo     = "info"
+	protocolBestMove = "bestmove"
+	protocolNoMove   = "none"
+)
+
+var cellChars = [3]byte{'.', 'r', 'y'}
+
+// FormatPosition writes board in protocol notation.
+func FormatPosition(board *models.Bitboard) string {
+	b := board.Board()
+	var sb strings.Builder
+	for row := 0; row < 6; row++ {
+		if row > 0 {
+			sb.WriteByte('/')
+		}
+		for col := 0; col < 7; col++ {
+			sb.WriteByte(cellChars[b[row][col]])
+		}
+	}
+	return sb.String()
+}
+
+// ParsePosition reads a board in protocol notation, rejecting discs that
+// float above empty cells and disc counts that cannot occur with red moving
+// first.
+func ParsePosition(s string) (models.Bitboard, error) {
+	rows := strings.Split(s, "/")
+	if len(rows) != 6 {
+		return models.Bitboard{}, fmt.Errorf("position must have 6 rows, got %d", len(rows))
+	}
+	var b models.Board
+	red, yellow := 0, 0
+	for row, cells := range rows {
+		if len(cells) != 7 {
+			return models.Bitboard{}, fmt.Errorf("row %d must have 7 cells", row+1)
+		}
+		for col := 0; col < 7; col++ {
+			switch cells[col] {
+			case '.':
+			case 'r':
+				b[row][col] = 1
+				red++
+			case 'y':
+				b[row][col] = 2
+				yellow++
+			default:
+				return models.Bitboard{}, fmt.Errorf("invalid cell %q", cells[col])
+			}
+		}
+	}
+	if red != yellow && red != yellow+1 {
+		return models.Bitboard{}, fmt.Errorf("impossible disc counts: %d red, %d yellow", red, yellow)
+	}
+	board := models.BitboardFromBoard(b)
+	if board.MoveCount() != red+yellow {
+		return models.Bitboard{}, fmt.Errorf("position has floating discs")
+	}
+	return board, nil
+}
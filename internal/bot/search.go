@@ -0,0 +1,313 @@
+package bot
+
+import (
+	"connect4/internal/models"
+	"context"
+	"math"
+	"time"
+)
+
+// centerFirst is the static move order: central columns take part in more
+// four-in-a-row windows, so they are most likely to cause a cutoff.
+var centerFirst = [7]int{3, 2, 4, 1, 5, 0, 6}
+
+var leftToRight = [7]int{0, 1, 2, 3, 4, 5, 6}
+
+const maxPly = 42
+
+// SearchOptions switches off individual search enhancements. The zero value
+// is what GetBestMove uses; the switches exist so their effect on node
+// counts can be measured.
+type SearchOptions struct {
+	DisableTable    bool
+	DisableOrdering bool
+}
+
+// BenchPosition is a position to measure search work on, given as the
+// moves (0-based columns, red first) that reach it.
+type BenchPosition struct {
+	Name  string
+	Moves []int
+}
+
+// BenchPositions cover the opening, an early middlegame and a crowded
+// middlegame. cmd/botbench and the search benchmarks both use them.
+var BenchPositions = []BenchPosition{
+	{"empty", nil},
+	{"opening", []int{3, 3, 2}},
+	{"middlegame", []int{3, 3, 3, 2, 4, 4, 2, 5}},
+	{"crowded", []int{3, 3, 3, 3, 2, 4, 4, 2, 5, 1, 1, 5, 0, 6}},
+}
+
+// Board plays the position's moves from the empty board.
+func (p BenchPosition) Board() models.Board {
+	board := models.NewBoard()
+	for i, col := range p.Moves {
+		board.DropDisc(col, i%2+1)
+	}
+	return board
+}
+
+type SearchStats struct {
+	Depth     int
+	Move      int
+	Score     float64
+	PV        []int
+	Nodes     int
+	TableHits int
+	Elapsed   time.Duration
+}
+
+// Analyze searches the position to exactly maxDepth with no time limit and
+// reports how much work it took.
+func (b *Bot) Analyze(board models.Board, maxDepth int, opts SearchOptions) SearchStats {
+	_, stats := b.iterate(context.Background(), models.BitboardFromBoard(board), maxDepth, time.Time{}, opts)
+	return stats
+}
+
+// iterate deepens one ply at a time until maxDepth, the deadline or ctx
+// cancellation, and returns the best column for the side to move from the
+// last fully completed iteration.
+func (b *Bot) iterate(ctx context.Context, board models.Bitboard, maxDepth int, deadline time.Time, opts SearchOptions) (int, SearchStats) {
+	s := newSearch(b, board.MoveCount()%2+1, opts)
+	defer s.release()
+
+	start := time.Now()
+	var stats SearchStats
+	bestCol := -1
+	for depth := 1; depth <= maxDepth; depth++ {
+		// Depth 1 always completes so there is a move to fall back on.
+		if depth > 1 {
+			s.ctx = ctx
+			s.deadline = deadline
+		}
+		col, score, ok := s.searchRoot(board, depth, bestCol)
+		if !ok {
+			break
+		}
+		bestCol = col
+		stats.Depth = depth
+		stats.Move = col
+		stats.Score = score
+		stats.PV = s.principalVariation(board, col, depth)
+	}
+	stats.Nodes = s.nodes
+	stats.TableHits = s.tableHits
+	stats.Elapsed = time.Since(start)
+	return bestCol, stats
+}
+
+type search struct {
+	bot       *Bot
+	me, opp   int
+	ctx       context.Context
+	deadline  time.Time
+	table     *table
+	ordered   bool
+	killers   [maxPly + 1][2]int
+	nodes     int
+	tableHits int
+	stopped   bool
+}
+
+// newSearch returns a search that maximizes for player me.
+func newSearch(b *Bot, me int, opts SearchOptions) *search {
+	s := &search{bot: b, me: me, opp: 3 - me, ordered: !opts.DisableOrdering}
+	if !opts.DisableTable {
+		s.table = acquireTable()
+	}
+	for i := range s.killers {
+		s.killers[i] = [2]int{-1, -1}
+	}
+	return s
+}
+
+func (s *search) release() {
+	if s.table != nil {
+		releaseTable(s.table)
+		s.table = nil
+	}
+}
+
+// searchRoot runs one fixed-depth iteration, trying the previous
+// iteration's best column first. It reports false if the deadline
+// interrupted the iteration, in which case the result must be discarded.
+func (s *search) searchRoot(board models.Bitboard, depth int, firstCol int) (int, float64, bool) {
+	hash := zobristHash(&board)
+	bestScore := math.Inf(-1)
+	bestCol := -1
+	for _, col := range s.orderMoves(firstCol, 0) {
+		if !board.IsValidMove(col) {
+			continue
+		}
+		next := board
+		row := next.DropDisc(col, s.me)
+		score := s.minimax(next, hash^zobrist[row][col][s.me], depth-1, 1, math.Inf(-1), math.Inf(1), false)
+		if s.stopped {
+			return -1, 0, false
+		}
+		if col == 3 {
+			score += 0.1
+		}
+		if score > bestScore {
+			bestScore = score
+			bestCol = col
+		}
+	}
+	return bestCol, bestScore, true
+}
+
+func (s *search) expired() bool {
+	if s.stopped {
+		return true
+	}
+	s.nodes++
+	if s.nodes%1024 != 0 {
+		return false
+	}
+	if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		s.stopped = true
+	}
+	if s.ctx != nil && s.ctx.Err() != nil {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+// principalVariation follows hash moves from the root to recover the line
+// the search expects, starting with first.
+func (s *search) principalVariation(board models.Bitboard, first, depth int) []int {
+	pv := []int{first}
+	hash := zobristHash(&board)
+	player := s.me
+	col := first
+	for len(pv) < depth && s.table != nil {
+		if board.IsWinningMove(col, player) {
+			break
+		}
+		row := board.DropDisc(col, player)
+		hash ^= zobrist[row][col][player]
+		player = 3 - player
+		e, ok := s.table.probe(hash)
+		if !ok || !board.IsValidMove(int(e.move)) {
+			break
+		}
+		col = int(e.move)
+		pv = append(pv, col)
+	}
+	return pv
+}
+
+// orderMoves returns the columns in the order they should be tried: the
+// hash move, then this ply's killer moves, then center-first.
+func (s *search) orderMoves(hashMove, ply int) []int {
+	if !s.ordered {
+		order := leftToRight
+		return order[:]
+	}
+	order := make([]int, 0, 7)
+	var seen [7]bool
+	add := func(col int) {
+		if col >= 0 && !seen[col] {
+			seen[col] = true
+			order = append(order, col)
+		}
+	}
+	add(hashMove)
+	add(s.killers[ply][0])
+	add(s.killers[ply][1])
+	for _, col := range centerFirst {
+		add(col)
+	}
+	return order
+}
+
+func (s *search) storeKiller(ply, col int) {
+	if !s.ordered || s.killers[ply][0] == col {
+		return
+	}
+	s.killers[ply][1] = s.killers[ply][0]
+	s.killers[ply][0] = col
+}
+
+func (s *search) minimax(board models.Bitboard, hash uint64, depth, ply int, alpha, beta float64, isMaximizing bool) float64 {
+	if s.expired() {
+		return 0
+	}
+	if depth == 0 || board.IsFull() {
+		return s.bot.evaluateBoard(&board, s.me)
+	}
+
+	alphaOrig, betaOrig := alpha, beta
+	hashMove := -1
+	if s.table != nil {
+		if e, ok := s.table.probe(hash); ok {
+			hashMove = int(e.move)
+			if int(e.depth) >= depth {
+				s.tableHits++
+				switch e.flag {
+				case boundExact:
+					return e.score
+				case boundLower:
+					alpha = math.Max(alpha, e.score)
+				case boundUpper:
+					beta = math.Min(beta, e.score)
+				}
+				if beta <= alpha {
+					return e.score
+				}
+			}
+		}
+	}
+
+	player, best := s.opp, math.Inf(1)
+	if isMaximizing {
+		player, best = s.me, math.Inf(-1)
+	}
+	bestMove := -1
+
+	for _, col := range s.orderMoves(hashMove, ply) {
+		if !board.IsValidMove(col) {
+			continue
+		}
+		if board.IsWinningMove(col, player) {
+			if isMaximizing {
+				return winScore + float64(depth)
+			}
+			return -winScore - float64(depth)
+		}
+		next := board
+		row := next.DropDisc(col, player)
+		eval := s.minimax(next, hash^zobrist[row][col][player], depth-1, ply+1, alpha, beta, !isMaximizing)
+		if s.stopped {
+			return 0
+		}
+
+		if isMaximizing {
+			if eval > best {
+				best, bestMove = eval, col
+			}
+			alpha = math.Max(alpha, eval)
+		} else {
+			if eval < best {
+				best, bestMove = eval, col
+			}
+			beta = math.Min(beta, eval)
+		}
+		if beta <= alpha {
+			s.storeKiller(ply, col)
+			break
+		}
+	}
+
+	if s.table != nil {
+		flag := boundExact
+		if best <= alphaOrig {
+			flag = boundUpper
+		} else if best >= betaOrig {
+			flag = boundLower
+		}
+		s.table.store(hash, depth, flag, best, bestMove)
+	}
+	return best
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"connect4/internal/models"
+	"context"
+	"testing"
+)
+
+func benchBoard(moves []int) models.Board {
+	return BenchPosition{Moves: moves}.Board()
+}
+
+// BenchmarkBestMove times a fixed-depth search with and without the
+// transposition table and move ordering, reporting the nodes searched.
+func BenchmarkBestMove(b *testing.B) {
+	variants := []struct {
+		name string
+		opts SearchOptions
+	}{
+		{"plain", SearchOptions{DisableTable: true, DisableOrdering: true}},
+		{"table+ordering", SearchOptions{}},
+	}
+	bot := New(DifficultyHard, 0, nil)
+	for _, p := range BenchPositions {
+		board := p.Board()
+		for _, v := range variants {
+			b.Run(p.Name+"/"+v.name, func(b *testing.B) {
+				var nodes int
+				for i := 0; i < b.N; i++ {
+					nodes = bot.Analyze(board, 8, v.opts).Nodes
+				}
+				b.ReportMetric(float64(nodes), "nodes/op")
+			})
+		}
+	}
+}
+
+// BenchmarkEngineBestMove times a whole move as the server asks for it,
+// for each difficulty, with no time budget.
+func BenchmarkEngineBestMove(b *testing.B) {
+	board := models.BitboardFromBoard(BenchPositions[2].Board())
+	for _, d := range []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard} {
+		bot := New(d, 0, nil)
+		b.Run(string(d), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bot.BestMove(context.Background(), board); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBestMoveTakesImmediateWin(t *testing.T) {
+	// Red has columns 0-2 of the bottom row and wins by playing column 3.
+	board := benchBoard([]int{0, 0, 1, 1, 2, 2})
+	for _, d := range []Difficulty{DifficultyMedium, DifficultyHard} {
+		move, err := New(d, 0, nil).BestMove(context.Background(), models.BitboardFromBoard(board))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if move.Column != 3 {
+			t.Errorf("%s played column %d, want the winning 3", d, move.Column)
+		}
+	}
+}
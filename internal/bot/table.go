@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"connect4/internal/models"
+	"math/rand"
+	"sync"
+)
+
+const tableSize = 1 << 18
+
+type bound uint8
+
+const (
+	boundExact bound = iota
+	boundLower
+	boundUpper
+)
+
+// zobrist holds one random key per cell and disc; a position's hash is the
+// XOR of the keys of its occupied cells, so it can be updated per move.
+var zobrist [6][7][3]uint64
+
+func init() {
+	rng := rand.New(rand.NewSource(0x5eed))
+	for row := range zobrist {
+		for col := range zobrist[row] {
+			for p := range zobrist[row][col] {
+				zobrist[row][col][p] = rng.Uint64()
+			}
+		}
+	}
+}
+
+func zobristHash(board *models.Bitboard) uint64 {
+	var h uint64
+	b := board.Board()
+	for row := 0; row < 6; row++ {
+		for col := 0; col < 7; col++ {
+			if p := b[row][col]; p != 0 {
+				h ^= zobrist[row][col][p]
+			}
+		}
+	}
+	return h
+}
+
+type tableEntry struct {
+	key   uint64
+	score float64
+	gen   uint32
+	depth int8
+	move  int8
+	flag  bound
+}
+
+// table is a fixed-size, always-replace transposition table. Tables are
+// pooled between searches; bumping gen invalidates every old entry without
+// clearing memory.
+type table struct {
+	entries []tableEntry
+	gen     uint32
+}
+
+var tablePool = sync.Pool{
+	New: func() interface{} {
+		return &table{entries: make([]tableEntry, tableSize)}
+	},
+}
+
+func acquireTable() *table {
+	t := tablePool.Get().(*table)
+	t.gen++
+	return t
+}
+
+func releaseTable(t *table) {
+	tablePool.Put(t)
+}
+
+func (t *table) probe(key uint64) (tableEntry, bool) {
+	e := t.entries[key&(tableSize-1)]
+	if e.gen != t.gen || e.key != key {
+		return tableEntry{}, false
+	}
+	return e, true
+}
+
+func (t *table) store(key uint64, depth int, flag bound, score float64, move int) {
+	t.entries[key&(tableSize-1)] = tableEntry{
+		key:   key,
+		score: score,
+		gen:   t.gen,
+		depth: int8(depth),
+		move:  int8(move),
+		flag:  flag,
+	}
+}
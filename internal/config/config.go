@@ -5,6 +5,7 @@ import (
 	
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +19,8 @@ type Config struct {
 type ServerConfig struct {
 	Port string
 	Env  string
+	// SessionTTL is how many hours a sign-in lasts.
+	SessionTTL int
 }
 
 // type DatabaseConfig struct {
@@ -36,6 +39,50 @@ type DatabaseConfig struct {
 type GameConfig struct {
 	MatchmakingTimeout  int
 	ReconnectionTimeout int
+	BotMoveBudgetMs     int
+	SolverBookPath      string
+	BotBookPath         string
+	BotEngine           string
+	ExternalEngines     []ExternalEngineConfig
+
+	// Matchmaking accepts opponents within MatchmakingRatingWindow rating
+	// points, widening by MatchmakingWindowGrowth points per second of
+	// waiting up to MatchmakingMaxWindow.
+	MatchmakingRatingWindow int
+	MatchmakingWindowGrowth int
+	MatchmakingMaxWindow    int
+
+	// RoomIdleTimeout is how many seconds a private room may sit idle
+	// before it expires.
+	RoomIdleTimeout int
+	// ChallengeTimeout is how many seconds a challenge waits for an answer.
+	ChallengeTimeout int
+	// DefaultTimeControl applies to matchmaking players who do not pick
+	// one, e.g. "5+3" or "move:30"; empty means untimed.
+	DefaultTimeControl string
+	// MoveIdleTimeout is how many seconds a player in an untimed game may
+	// take over a move before they forfeit; zero turns the limit off. They
+	// are warned MoveIdleWarning seconds beforehand.
+	MoveIdleTimeout int
+	MoveIdleWarning int
+
+	// Chat messages may be up to ChatMaxLength characters, and each user may
+	// send ChatRateLimit of them per ChatRateWindow seconds. ChatBlockedWords
+	// are masked out of messages.
+	ChatMaxLength    int
+	ChatRateLimit    int
+	ChatRateWindow   int
+	ChatBlockedWords []string
+
+	// LeaderboardReconcileInterval is how many seconds pass between checks
+	// of the cached leaderboards against the database.
+	LeaderboardReconcileInterval int
+}
+
+// ExternalEngineConfig names an executable that speaks the bot protocol.
+type ExternalEngineConfig struct {
+	Name    string
+	Command []string
 }
 
 func Load() (*Config, error) {
@@ -45,6 +92,8 @@ func Load() (*Config, error) {
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
 			Env:  getEnv("ENV", "development"),
+
+			SessionTTL: getEnvAsInt("SESSION_TTL", 720),
 		},
 		// Database: DatabaseConfig{
 		// 	Host:     getEnv("DB_HOST", "localhost"),
@@ -61,6 +110,28 @@ func Load() (*Config, error) {
 		Game: GameConfig{
 			MatchmakingTimeout:  getEnvAsInt("MATCHMAKING_TIMEOUT", 10),
 			ReconnectionTimeout: getEnvAsInt("RECONNECTION_TIMEOUT", 30),
+			BotMoveBudgetMs:     getEnvAsInt("BOT_MOVE_BUDGET_MS", 500),
+			SolverBookPath:      getEnv("SOLVER_BOOK_PATH", ""),
+			BotBookPath:         getEnv("BOT_BOOK_PATH", ""),
+			BotEngine:           getEnv("BOT_ENGINE", ""),
+			ExternalEngines:     parseExternalEngines(getEnv("EXTERNAL_ENGINES", "")),
+
+			MatchmakingRatingWindow: getEnvAsInt("MATCHMAKING_RATING_WINDOW", 100),
+			MatchmakingWindowGrowth: getEnvAsInt("MATCHMAKING_WINDOW_GROWTH", 25),
+			MatchmakingMaxWindow:    getEnvAsInt("MATCHMAKING_MAX_WINDOW", 400),
+
+			RoomIdleTimeout:    getEnvAsInt("ROOM_IDLE_TIMEOUT", 600),
+			ChallengeTimeout:   getEnvAsInt("CHALLENGE_TIMEOUT", 30),
+			DefaultTimeControl: getEnv("DEFAULT_TIME_CONTROL", ""),
+			MoveIdleTimeout:    getEnvAsInt("MOVE_IDLE_TIMEOUT", 0),
+			MoveIdleWarning:    getEnvAsInt("MOVE_IDLE_WARNING", 15),
+
+			ChatMaxLength:    getEnvAsInt("CHAT_MAX_LENGTH", 200),
+			ChatRateLimit:    getEnvAsInt("CHAT_RATE_LIMIT", 5),
+			ChatRateWindow:   getEnvAsInt("CHAT_RATE_WINDOW", 10),
+			ChatBlockedWords: parseWordList(getEnv("CHAT_BLOCKED_WORDS", "")),
+
+			LeaderboardReconcileInterval: getEnvAsInt("LEADERBOARD_RECONCILE_INTERVAL", 300),
 		},
 	}
 
@@ -94,6 +165,33 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// parseExternalEngines reads "name=command arg ...;name2=command2", skipping
+// malformed entries.
+func parseExternalEngines(value string) []ExternalEngineConfig {
+	var engines []ExternalEngineConfig
+	for _, entry := range strings.Split(value, ";") {
+		name, command, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(command)
+		if !ok || name == "" || len(fields) == 0 {
+			continue
+		}
+		engines = append(engines, ExternalEngineConfig{Name: name, Command: fields})
+	}
+	return engines
+}
+
+// parseWordList reads comma-separated words, skipping empty entries.
+func parseWordList(value string) []string {
+	var words []string
+	for _, word := range strings.Split(value, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	valueStr := os.Getenv(key)
 	if valueStr == "" {
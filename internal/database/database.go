@@ -2,14 +2,17 @@ package database
 
 import (
 	"connect4/internal/config"
+	"connect4/internal/leaderboard"
 	"connect4/internal/models"
+	"connect4/internal/rating"
 	"connect4/pkg/logger"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +39,12 @@ func New(cfg *config.Config) (*Database, error) {
 	return &Database{db: db}, nil
 }
 
+// Wrap returns a Database over an already opened pool, without checking
+// that it can connect.
+func Wrap(db *sql.DB) *Database {
+	return &Database{db: db}
+}
+
 func (d *Database) Close() error {
 	return d.db.Close()
 }
@@ -44,18 +53,24 @@ func (d *Database) Ping() error {
 	return d.db.Ping()
 }
 
+const playerColumns = `id, username, games_played, games_won, rating, rating_deviation, rating_volatility, is_guest, created_at, updated_at`
+
+func scanPlayer(row *sql.Row, player *models.Player) error {
+	return row.Scan(
+		&player.ID, &player.Username, &player.GamesPlayed, &player.GamesWon,
+		&player.Rating, &player.RatingDeviation, &player.RatingVolatility,
+		&player.IsGuest, &player.CreatedAt, &player.UpdatedAt,
+	)
+}
+
 func (d *Database) CreatePlayer(username string) (*models.Player, error) {
 	var player models.Player
 	query := `
 		INSERT INTO players (username) 
 		VALUES ($1) 
 		ON CONFLICT (username) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
-		RETURNING id, username, games_played, games_won, created_at, updated_at
-	`
-	err := d.db.QueryRow(query, username).Scan(
-		&player.ID, &player.Username, &player.GamesPlayed,
-		&player.GamesWon, &player.CreatedAt, &player.UpdatedAt,
-	)
+		RETURNING ` + playerColumns
+	err := scanPlayer(d.db.QueryRow(query, username), &player)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create player: %w", err)
 	}
@@ -64,11 +79,8 @@ func (d *Database) CreatePlayer(username string) (*models.Player, error) {
 
 func (d *Database) GetPlayerByUsername(username string) (*models.Player, error) {
 	var player models.Player
-	query := `SELECT id, username, games_played, games_won, created_at, updated_at FROM players WHERE username = $1`
-	err := d.db.QueryRow(query, username).Scan(
-		&player.ID, &player.Username, &player.GamesPlayed,
-		&player.GamesWon, &player.CreatedAt, &player.UpdatedAt,
-	)
+	query := `SELECT ` + playerColumns + ` FROM players WHERE username = $1`
+	err := scanPlayer(d.db.QueryRow(query, username), &player)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -78,10 +90,66 @@ func (d *Database) GetPlayerByUsername(username string) (*models.Player, error)
 	return &player, nil
 }
 
-func (d *Database) CreateGame(player1ID int, player2ID *int, isBot bool) (uuid.UUID, error) {
+func (d *Database) GetPlayerByID(id int) (*models.Player, error) {
+	var player models.Player
+	query := `SELECT ` + playerColumns + ` FROM players WHERE id = $1`
+	err := scanPlayer(d.db.QueryRow(query, id), &player)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player: %w", err)
+	}
+	return &player, nil
+}
+
+// SaveRating stores a player's new rating after a game along with the
+// history row recording the change.
+func (d *Database) SaveRating(playerID int, gameID uuid.UUID, before float64, after rating.Rating) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rating update: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE players SET rating = $1, rating_deviation = $2, rating_volatility = $3 WHERE id = $4`
+	if _, err := tx.Exec(query, after.Value, after.Deviation, after.Volatility, playerID); err != nil {
+		return fmt.Errorf("failed to update rating: %w", err)
+	}
+	query = `INSERT INTO rating_history (player_id, game_id, rating_before, rating_after, rating_deviation, rating_volatility) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.Exec(query, playerID, gameID, before, after.Value, after.Deviation, after.Volatility); err != nil {
+		return fmt.Errorf("failed to save rating history: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rating update: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) GetRatingHistory(playerID int, limit int) ([]models.RatingChange, error) {
+	query := `SELECT game_id, rating_before, rating_after, rating_deviation, rating_volatility, created_at FROM rating_history WHERE player_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`
+	rows, err := d.db.Query(query, playerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rating history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.RatingChange
+	for rows.Next() {
+		var change models.RatingChange
+		err := rows.Scan(&change.GameID, &change.RatingBefore, &change.RatingAfter, &change.RatingDeviation, &change.RatingVolatility, &change.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rating history: %w", err)
+		}
+		history = append(history, change)
+	}
+	return history, nil
+}
+
+func (d *Database) CreateGame(player1ID int, player2ID *int, isBot bool, timeControl string) (uuid.UUID, error) {
 	gameID := uuid.New()
-	query := `INSERT INTO games (id, player1_id, player2_id, player2_is_bot, status, started_at) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := d.db.Exec(query, gameID, player1ID, player2ID, isBot, models.GameStatusActive, time.Now())
+	query := `INSERT INTO games (id, player1_id, player2_id, player2_is_bot, status, time_control, started_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := d.db.Exec(query, gameID, player1ID, player2ID, isBot, models.GameStatusActive, timeControl, time.Now())
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create game: %w", err)
 	}
@@ -109,22 +177,297 @@ func (d *Database) SaveGameMove(gameID uuid.UUID, playerID, column, row, moveNum
 	return nil
 }
 
-func (d *Database) GetLeaderboard(limit int) ([]models.LeaderboardEntry, error) {
-	query := `SELECT id, username, games_won, games_played, win_rate, created_at FROM leaderboard LIMIT $1`
-	rows, err := d.db.Query(query, limit)
+// SaveGameAction records a game ending other than by a disc, such as a
+// resignation, after the moves already played.
+func (d *Database) SaveGameAction(gameID uuid.UUID, playerID int, action models.MoveAction, moveNumber int) error {
+	query := `INSERT INTO game_moves (game_id, player_id, action, move_number) VALUES ($1, $2, $3, $4)`
+	_, err := d.db.Exec(query, gameID, playerID, action, moveNumber)
+	if err != nil {
+		return fmt.Errorf("failed to save game action: %w", err)
+	}
+	return nil
+}
+
+// GetGameRecord returns a stored game with its players' names and moves,
+// or nil if there is no such game. A bot opponent is named "Bot".
+func (d *Database) GetGameRecord(gameID uuid.UUID) (*models.GameRecord, error) {
+	var record models.GameRecord
+	g := &record.Game
+	query := `SELECT g.id, g.player1_id, g.player2_id, g.player2_is_bot, g.winner_id, g.status, g.time_control,
+		g.duration_seconds, g.total_moves, g.started_at, g.completed_at, g.created_at,
+		p1.username, COALESCE(p2.username, 'Bot'), w.username
+		FROM games g
+		JOIN players p1 ON p1.id = g.player1_id
+		LEFT JOIN players p2 ON p2.id = g.player2_id
+		LEFT JOIN players w ON w.id = g.winner_id
+		WHERE g.id = $1`
+	err := d.db.QueryRow(query, gameID).Scan(
+		&g.ID, &g.Player1ID, &g.Player2ID, &g.Player2IsBot, &g.WinnerID, &g.Status, &g.TimeControl,
+		&g.DurationSeconds, &g.TotalMoves, &g.StartedAt, &g.CompletedAt, &g.CreatedAt,
+		&record.Player1, &record.Player2, &record.Winner,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get leaderboard: %w", err)
+		return nil, fmt.Errorf("failed to get game: %w", err)
+	}
+
+	record.Moves, err = d.GetGameMoves(g.ID, g.Player1ID)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetGameMoves returns a game's moves in order. Player 1 plays red.
+func (d *Database) GetGameMoves(gameID uuid.UUID, player1ID int) ([]models.GameMove, error) {
+	query := `SELECT move_number, player_id, action, column_index, row_index, created_at FROM game_moves WHERE game_id = $1 ORDER BY move_number, id`
+	rows, err := d.db.Query(query, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game moves: %w", err)
 	}
 	defer rows.Close()
 
-	var entries []models.LeaderboardEntry
+	moves := []models.GameMove{}
 	for rows.Next() {
-		var entry models.LeaderboardEntry
-		err := rows.Scan(&entry.ID, &entry.Username, &entry.GamesWon, &entry.GamesPlayed, &entry.WinRate, &entry.CreatedAt)
+		var m models.GameMove
+		if err := rows.Scan(&m.MoveNumber, &m.PlayerID, &m.Action, &m.Column, &m.Row, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan game move: %w", err)
+		}
+		m.Color = models.ColorYellow
+		if m.PlayerID == player1ID {
+			m.Color = models.ColorRed
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// playerGamesQuery lists every game of player $1 from their side: their
+// colour, opponent and result.
+const playerGamesQuery = `SELECT g.id, g.status, g.time_control, g.total_moves, g.duration_seconds, g.started_at, g.completed_at,
+		g.player2_is_bot AND g.player1_id = $1 AS opponent_is_bot,
+		CASE WHEN g.player1_id = $1 THEN 'red' ELSE 'yellow' END AS color,
+		CASE WHEN g.player1_id = $1 THEN COALESCE(p2.username, 'Bot') ELSE p1.username END AS opponent,
+		CASE
+			WHEN g.status = 'active' THEN 'ongoing'
+			WHEN g.winner_id IS NULL THEN 'draw'
+			WHEN g.winner_id = $1 THEN 'win'
+			ELSE 'loss'
+		END AS result
+	FROM games g
+	JOIN players p1 ON p1.id = g.player1_id
+	LEFT JOIN players p2 ON p2.id = g.player2_id
+	WHERE g.player1_id = $1 OR g.player2_id = $1`
+
+// GetPlayerGames returns a page of the player's games, newest first.
+func (d *Database) GetPlayerGames(playerID int, filter models.GameHistoryFilter) ([]models.GameHistoryEntry, error) {
+	args := []interface{}{playerID}
+	var conditions []string
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Result != "" {
+		where("h.result = $%d", filter.Result)
+	}
+	if filter.Opponent != "" {
+		where("h.opponent = $%d", filter.Opponent)
+	}
+	if filter.VsBot != nil {
+		where("h.opponent_is_bot = $%d", *filter.VsBot)
+	}
+	if filter.Status != "" {
+		where("h.status = $%d", filter.Status)
+	}
+	if filter.From != nil {
+		where("h.started_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("h.started_at < $%d", *filter.To)
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.StartedAt, filter.After.GameID)
+		conditions = append(conditions, fmt.Sprintf("(h.started_at, h.id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT id, status, time_control, total_moves, duration_seconds, started_at, completed_at, opponent_is_bot, color, opponent, result FROM (` + playerGamesQuery + `) h`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY h.started_at DESC, h.id DESC LIMIT $%d`, len(args))
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player games: %w", err)
+	}
+	defer rows.Close()
+
+	games := []models.GameHistoryEntry{}
+	for rows.Next() {
+		var g models.GameHistoryEntry
+		err := rows.Scan(&g.GameID, &g.Status, &g.TimeControl, &g.TotalMoves, &g.DurationSeconds, &g.StartedAt, &g.CompletedAt,
+			&g.OpponentIsBot, &g.Color, &g.Opponent, &g.Result)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan leaderboard entry: %w", err)
+			return nil, fmt.Errorf("failed to scan player game: %w", err)
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+// GetHeadToHead totals the finished games between players a and b. The
+// caller fills in the names and recent games.
+func (d *Database) GetHeadToHead(a, b int) (*models.HeadToHead, error) {
+	var h models.HeadToHead
+	query := `SELECT COUNT(*),
+		COUNT(*) FILTER (WHERE winner_id = $1),
+		COUNT(*) FILTER (WHERE winner_id = $2),
+		COUNT(*) FILTER (WHERE winner_id IS NULL),
+		COUNT(*) FILTER (WHERE status = 'forfeited' AND winner_id = $2),
+		COUNT(*) FILTER (WHERE status = 'forfeited' AND winner_id = $1),
+		COALESCE(AVG(total_moves), 0),
+		COALESCE(AVG(duration_seconds), 0)
+		FROM games
+		WHERE status <> 'active' AND ((player1_id = $1 AND player2_id = $2) OR (player1_id = $2 AND player2_id = $1))`
+	err := d.db.QueryRow(query, a, b).Scan(&h.GamesPlayed, &h.PlayerAWins, &h.PlayerBWins, &h.Draws,
+		&h.PlayerAForfeits, &h.PlayerBForfeits, &h.AverageMoves, &h.AverageDurationSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get head-to-head: %w", err)
+	}
+	return &h, nil
+}
+
+// SaveChatMessage stores a chat message, filling in its ID and time.
+func (d *Database) SaveChatMessage(msg *models.ChatMessage) error {
+	query := `INSERT INTO chat_messages (game_id, username, is_spectator, message) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := d.db.QueryRow(query, msg.GameID, msg.Username, msg.IsSpectator, msg.Message).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save chat message: %w", err)
+	}
+	return nil
+}
+
+// leaderboardQuery ranks players by their finished games since $1, or
+// ever if $1 is NULL, leaving out games against the bot if $2 is true. The
+// ranking order is spliced in.
+const leaderboardQuery = `WITH results AS (
+		SELECT g.player1_id AS player_id, g.winner_id = g.player1_id AS won, COALESCE(g.player2_is_bot, FALSE) AS vs_bot
+		FROM games g
+		WHERE g.status <> 'active' AND ($1::timestamp IS NULL OR g.completed_at >= $1::timestamp)
+		UNION ALL
+		SELECT g.player2_id, g.winner_id = g.player2_id, FALSE
+		FROM games g
+		WHERE g.status <> 'active' AND g.player2_id IS NOT NULL AND ($1::timestamp IS NULL OR g.completed_at >= $1::timestamp)
+	), stats AS (
+		SELECT player_id, COUNT(*) FILTER (WHERE won) AS games_won, COUNT(*) AS games_played
+		FROM results
+		WHERE NOT ($2::boolean AND vs_bot)
+		GROUP BY player_id
+	), ranked AS (
+		SELECT p.id, p.username, s.games_won, s.games_played,
+			ROUND(s.games_won::NUMERIC / s.games_played * 100, 2) AS win_rate,
+			p.rating, p.rating_deviation, p.created_at
+		FROM stats s JOIN players p ON p.id = s.player_id
+	)
+	SELECT RANK() OVER (ORDER BY %s) AS rank, id, username, games_won, games_played, win_rate, rating, rating_deviation, created_at,
+		COUNT(*) OVER () AS total
+	FROM ranked`
+
+func leaderboardQueryFor(sortBy models.LeaderboardSort) string {
+	orderBy := `games_won DESC, win_rate DESC, games_played DESC`
+	if sortBy == models.LeaderboardSortRating {
+		orderBy = `rating DESC, games_played DESC`
+	}
+	return fmt.Sprintf(leaderboardQuery, orderBy)
+}
+
+// GetLeaderboard returns a page of the leaderboard and how many players are
+// on it in all.
+func (d *Database) GetLeaderboard(q models.LeaderboardQuery) ([]models.LeaderboardEntry, int, error) {
+	since := q.Period.Since(time.Now())
+	query := `SELECT * FROM (` + leaderboardQueryFor(q.Sort) + `) l ORDER BY l.rank, l.id LIMIT $3 OFFSET $4`
+	rows, err := d.db.Query(query, since, q.ExcludeBots, q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	total := 0
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		if err := scanLeaderboardEntry(rows, &entry, &total); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan leaderboard entry: %w", err)
 		}
 		entries = append(entries, entry)
 	}
-	return entries, nil
-}
\ No newline at end of file
+
+	// A page past the end has no rows to carry the total.
+	if len(entries) == 0 && q.Offset > 0 {
+		query := `SELECT COUNT(*) FROM (` + leaderboardQueryFor(q.Sort) + `) l`
+		if err := d.db.QueryRow(query, since, q.ExcludeBots).Scan(&total); err != nil {
+			return nil, 0, fmt.Errorf("failed to count leaderboard: %w", err)
+		}
+	}
+	return entries, total, nil
+}
+
+// GetLeaderboardRank returns username's place on the leaderboard, or nil if
+// they are not on it.
+func (d *Database) GetLeaderboardRank(q models.LeaderboardQuery, username string) (*models.LeaderboardEntry, error) {
+	query := `SELECT * FROM (` + leaderboardQueryFor(q.Sort) + `) l WHERE l.username = $3`
+	var entry models.LeaderboardEntry
+	var total int
+	err := scanLeaderboardEntry(d.db.QueryRow(query, q.Period.Since(time.Now()), q.ExcludeBots, username), &entry, &total)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard rank: %w", err)
+	}
+	return &entry, nil
+}
+
+// GetLeaderboardStats returns the all-time record of every player with a
+// finished game, or of just the given players if ids is not nil.
+func (d *Database) GetLeaderboardStats(ids []int64) ([]leaderboard.Stats, error) {
+	query := `WITH results AS (
+		SELECT g.player1_id AS player_id, g.winner_id = g.player1_id AS won, COALESCE(g.player2_is_bot, FALSE) AS vs_bot
+		FROM games g
+		WHERE g.status <> 'active' AND ($1::int[] IS NULL OR g.player1_id = ANY($1::int[]))
+		UNION ALL
+		SELECT g.player2_id, g.winner_id = g.player2_id, FALSE
+		FROM games g
+		WHERE g.status <> 'active' AND g.player2_id IS NOT NULL AND ($1::int[] IS NULL OR g.player2_id = ANY($1::int[]))
+	)
+	SELECT p.id, p.username, p.rating, p.rating_deviation, p.created_at,
+		COUNT(*) FILTER (WHERE r.won), COUNT(*),
+		COUNT(*) FILTER (WHERE r.won AND NOT r.vs_bot), COUNT(*) FILTER (WHERE NOT r.vs_bot)
+	FROM results r JOIN players p ON p.id = r.player_id
+	GROUP BY p.id`
+	rows, err := d.db.Query(query, pq.Int64Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []leaderboard.Stats
+	for rows.Next() {
+		var s leaderboard.Stats
+		err := rows.Scan(&s.ID, &s.Username, &s.Rating, &s.RatingDeviation, &s.CreatedAt,
+			&s.GamesWon, &s.GamesPlayed, &s.HumanGamesWon, &s.HumanGamesPlayed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan leaderboard stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+func scanLeaderboardEntry(row rowScanner, entry *models.LeaderboardEntry, total *int) error {
+	return row.Scan(&entry.Rank, &entry.ID, &entry.Username, &entry.GamesWon, &entry.GamesPlayed, &entry.WinRate,
+		&entry.Rating, &entry.RatingDeviation, &entry.CreatedAt, total)
+}
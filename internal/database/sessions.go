@@ -0,0 +1,93 @@
+package database
+
+import (
+	"connect4/internal/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateAccount adds a player with a password hash, or a guest with none.
+// It returns nil if the username is taken.
+func (d *Database) CreateAccount(username, passwordHash string, isGuest bool) (*models.Player, error) {
+	var player models.Player
+	var hash *string
+	if passwordHash != "" {
+		hash = &passwordHash
+	}
+	query := `
+		INSERT INTO players (username, password_hash, is_guest)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (username) DO NOTHING
+		RETURNING ` + playerColumns
+	err := scanPlayer(d.db.QueryRow(query, username, hash, isGuest), &player)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+	return &player, nil
+}
+
+// GetPasswordHash returns the player's ID and password hash. The hash is
+// empty if the player does not exist or has no password.
+func (d *Database) GetPasswordHash(username string) (int, string, error) {
+	var id int
+	var hash sql.NullString
+	query := `SELECT id, password_hash FROM players WHERE username = $1`
+	err := d.db.QueryRow(query, username).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+	return id, hash.String, nil
+}
+
+// CreateSession stores a session for the player that lasts ttl and returns
+// when it expires. Only a hash of the token is stored.
+func (d *Database) CreateSession(tokenHash string, playerID int, ttl time.Duration) (time.Time, error) {
+	var expiresAt time.Time
+	query := `
+		INSERT INTO sessions (token_hash, player_id, expires_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 second')
+		RETURNING expires_at`
+	if err := d.db.QueryRow(query, tokenHash, playerID, int64(ttl.Seconds())).Scan(&expiresAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	return expiresAt, nil
+}
+
+// GetSessionPlayer returns the player signed in with the token, or nil if
+// there is no such session or it has expired.
+func (d *Database) GetSessionPlayer(tokenHash string) (*models.Player, error) {
+	var player models.Player
+	query := `SELECT ` + playerColumns + ` FROM players WHERE id = (
+		SELECT player_id FROM sessions WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP)`
+	err := scanPlayer(d.db.QueryRow(query, tokenHash), &player)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &player, nil
+}
+
+func (d *Database) DeleteSession(tokenHash string) error {
+	query := `DELETE FROM sessions WHERE token_hash = $1`
+	if _, err := d.db.Exec(query, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) DeleteExpiredSessions() error {
+	query := `DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP`
+	if _, err := d.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"connect4/internal/tournaments"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const tournamentColumns = `t.id, t.name, t.format, t.status, t.rounds, t.current_round, p.username,
+	(SELECT COUNT(*) FROM tournament_players tp WHERE tp.tournament_id = t.id),
+	t.created_at, t.started_at, t.completed_at`
+
+const tournamentFrom = ` FROM tournaments t JOIN players p ON p.id = t.created_by`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTournament(row rowScanner, t *tournaments.Tournament) error {
+	return row.Scan(
+		&t.ID, &t.Name, &t.Format, &t.Status, &t.Rounds, &t.CurrentRound, &t.CreatedBy,
+		&t.PlayerCount, &t.CreatedAt, &t.StartedAt, &t.CompletedAt,
+	)
+}
+
+func (d *Database) CreateTournament(name string, format tournaments.Format, rounds int, createdBy int) (int, error) {
+	var id int
+	query := `INSERT INTO tournaments (name, format, rounds, created_by) VALUES ($1, $2, $3, $4) RETURNING id`
+	if err := d.db.QueryRow(query, name, format, rounds, createdBy).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create tournament: %w", err)
+	}
+	return id, nil
+}
+
+func (d *Database) GetTournament(id int) (*tournaments.Tournament, error) {
+	var t tournaments.Tournament
+	query := `SELECT ` + tournamentColumns + tournamentFrom + ` WHERE t.id = $1`
+	err := scanTournament(d.db.QueryRow(query, id), &t)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tournament: %w", err)
+	}
+	return &t, nil
+}
+
+// ListTournaments returns the newest tournaments, optionally only those with
+// the given status.
+func (d *Database) ListTournaments(status tournaments.Status, limit int) ([]tournaments.Tournament, error) {
+	query := `SELECT ` + tournamentColumns + tournamentFrom + ` WHERE ($1::text = '' OR t.status = $1::text) ORDER BY t.created_at DESC LIMIT $2`
+	rows, err := d.db.Query(query, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tournaments: %w", err)
+	}
+	defer rows.Close()
+
+	var list []tournaments.Tournament
+	for rows.Next() {
+		var t tournaments.Tournament
+		if err := scanTournament(rows, &t); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament: %w", err)
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// AddTournamentPlayer registers a player, reporting false if they already
+// were.
+func (d *Database) AddTournamentPlayer(tournamentID, playerID int) (bool, error) {
+	query := `INSERT INTO tournament_players (tournament_id, player_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	result, err := d.db.Exec(query, tournamentID, playerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to add tournament player: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to add tournament player: %w", err)
+	}
+	return n > 0, nil
+}
+
+// GetTournamentPlayers returns the registered players in seed order, or
+// registration order before seeding.
+func (d *Database) GetTournamentPlayers(tournamentID int) ([]tournaments.Player, error) {
+	query := `SELECT p.id, p.username, p.rating, tp.seed FROM tournament_players tp JOIN players p ON p.id = tp.player_id WHERE tp.tournament_id = $1 ORDER BY tp.seed, tp.joined_at`
+	rows, err := d.db.Query(query, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tournament players: %w", err)
+	}
+	defer rows.Close()
+
+	var players []tournaments.Player
+	for rows.Next() {
+		var p tournaments.Player
+		if err := rows.Scan(&p.ID, &p.Username, &p.Rating, &p.Seed); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament player: %w", err)
+		}
+		players = append(players, p)
+	}
+	return players, nil
+}
+
+// StartTournament fixes the seeds and round count, stores the first
+// round's pairings and marks the tournament running, all at once, so a
+// running tournament always has a round to play.
+func (d *Database) StartTournament(tournamentID, rounds int, players []tournaments.Player, pairings []tournaments.Pairing) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tournament start: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range players {
+		query := `UPDATE tournament_players SET seed = $1 WHERE tournament_id = $2 AND player_id = $3`
+		if _, err := tx.Exec(query, p.Seed, tournamentID, p.ID); err != nil {
+			return fmt.Errorf("failed to seed tournament player: %w", err)
+		}
+	}
+	query := `UPDATE tournaments SET status = $1, rounds = $2, started_at = CURRENT_TIMESTAMP WHERE id = $3`
+	if _, err := tx.Exec(query, tournaments.StatusRunning, rounds, tournamentID); err != nil {
+		return fmt.Errorf("failed to start tournament: %w", err)
+	}
+	if err := saveRound(tx, tournamentID, 1, pairings); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tournament start: %w", err)
+	}
+	return nil
+}
+
+// SaveTournamentRound stores a round's pairings, filling in their IDs, and
+// makes it the current round.
+func (d *Database) SaveTournamentRound(tournamentID, round int, pairings []tournaments.Pairing) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin tournament round: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveRound(tx, tournamentID, round, pairings); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tournament round: %w", err)
+	}
+	return nil
+}
+
+func saveRound(tx *sql.Tx, tournamentID, round int, pairings []tournaments.Pairing) error {
+	for i := range pairings {
+		p := &pairings[i]
+		var player2 *int
+		if !p.IsBye() {
+			player2 = &p.Player2
+		}
+		query := `INSERT INTO tournament_pairings (tournament_id, round, board, player1_id, player2_id, game_id, result) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+		if err := tx.QueryRow(query, tournamentID, p.Round, p.Board, p.Player1, player2, p.GameID, p.Result).Scan(&p.ID); err != nil {
+			return fmt.Errorf("failed to save tournament pairing: %w", err)
+		}
+		p.TournamentID = tournamentID
+	}
+	query := `UPDATE tournaments SET current_round = $1 WHERE id = $2`
+	if _, err := tx.Exec(query, round, tournamentID); err != nil {
+		return fmt.Errorf("failed to update tournament round: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) FinishTournament(tournamentID int) error {
+	query := `UPDATE tournaments SET status = $1, completed_at = CURRENT_TIMESTAMP WHERE id = $2`
+	if _, err := d.db.Exec(query, tournaments.StatusFinished, tournamentID); err != nil {
+		return fmt.Errorf("failed to finish tournament: %w", err)
+	}
+	return nil
+}
+
+const pairingColumns = `id, tournament_id, round, board, player1_id, COALESCE(player2_id, 0), game_id, result`
+
+func scanPairing(row rowScanner, p *tournaments.Pairing) error {
+	return row.Scan(&p.ID, &p.TournamentID, &p.Round, &p.Board, &p.Player1, &p.Player2, &p.GameID, &p.Result)
+}
+
+func (d *Database) GetTournamentPairings(tournamentID int) ([]tournaments.Pairing, error) {
+	query := `SELECT ` + pairingColumns + ` FROM tournament_pairings WHERE tournament_id = $1 ORDER BY round, board`
+	rows, err := d.db.Query(query, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tournament pairings: %w", err)
+	}
+	defer rows.Close()
+
+	var pairings []tournaments.Pairing
+	for rows.Next() {
+		var p tournaments.Pairing
+		if err := scanPairing(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan tournament pairing: %w", err)
+		}
+		pairings = append(pairings, p)
+	}
+	return pairings, nil
+}
+
+// GetPairingByGame returns the pairing a game was played for, or nil if the
+// game is not part of a tournament.
+func (d *Database) GetPairingByGame(gameID uuid.UUID) (*tournaments.Pairing, error) {
+	var p tournaments.Pairing
+	query := `SELECT ` + pairingColumns + ` FROM tournament_pairings WHERE game_id = $1`
+	err := scanPairing(d.db.QueryRow(query, gameID), &p)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tournament pairing: %w", err)
+	}
+	return &p, nil
+}
+
+func (d *Database) SetPairingResult(pairingID int, result tournaments.Result) error {
+	query := `UPDATE tournament_pairings SET result = $1 WHERE id = $2`
+	if _, err := d.db.Exec(query, result, pairingID); err != nil {
+		return fmt.Errorf("failed to set pairing result: %w", err)
+	}
+	return nil
+}
+
+// SetPairingGame points a pairing at a new game, as when a drawn knockout
+// game is replayed.
+func (d *Database) SetPairingGame(pairingID int, gameID uuid.UUID) error {
+	query := `UPDATE tournament_pairings SET game_id = $1 WHERE id = $2`
+	if _, err := d.db.Exec(query, gameID, pairingID); err != nil {
+		return fmt.Errorf("failed to set pairing game: %w", err)
+	}
+	return nil
+}
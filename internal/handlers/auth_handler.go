@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"connect4/internal/middleware"
+	"connect4/internal/models"
+	"connect4/internal/services"
+	"connect4/internal/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	authService *services.AuthService
+}
+
+func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+	return &AuthHandler{authService: authService}
+}
+
+func (h *AuthHandler) Register(c *gin.Context) {
+	var payload models.CredentialsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_CREDENTIALS", "Username and password are required")
+		return
+	}
+
+	session, err := h.authService.Register(payload.Username, payload.Password)
+	switch {
+	case err == nil:
+		utils.SuccessResponse(c, http.StatusCreated, session)
+	case errors.Is(err, services.ErrInvalidUsername), errors.Is(err, services.ErrInvalidPassword):
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_CREDENTIALS", err.Error())
+	case errors.Is(err, services.ErrUsernameTaken):
+		utils.ErrorResponse(c, http.StatusConflict, "USERNAME_TAKEN", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "AUTH_ERROR", "Failed to register")
+	}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var payload models.CredentialsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_CREDENTIALS", "Username and password are required")
+		return
+	}
+
+	session, err := h.authService.Login(payload.Username, payload.Password)
+	switch {
+	case err == nil:
+		utils.SuccessResponse(c, http.StatusOK, session)
+	case errors.Is(err, services.ErrInvalidCredentials):
+		utils.ErrorResponse(c, http.StatusUnauthorized, "INVALID_CREDENTIALS", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "AUTH_ERROR", "Failed to log in")
+	}
+}
+
+// Guest signs in a new guest identity, for playing without an account.
+func (h *AuthHandler) Guest(c *gin.Context) {
+	session, err := h.authService.Guest()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "AUTH_ERROR", "Failed to create guest")
+		return
+	}
+	utils.SuccessResponse(c, http.StatusCreated, session)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if err := h.authService.Logout(middleware.BearerToken(c.Request)); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "AUTH_ERROR", "Failed to log out")
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, gin.H{})
+}
+
+func (h *AuthHandler) Me(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"player": middleware.CurrentPlayer(c),
+	})
+}
@@ -2,21 +2,28 @@ package handlers
 
 import (
 	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/internal/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type GameHandler struct {
-	db *database.Database
+	db          *database.Database
+	gameService *services.GameService
 }
 
-func NewGameHandler(db *database.Database) *GameHandler {
-	return &GameHandler{db: db}
+func NewGameHandler(db *database.Database, gameService *services.GameService) *GameHandler {
+	return &GameHandler{db: db, gameService: gameService}
 }
 
 func (gh *GameHandler) GetLeaderboard(c *gin.Context) {
-	leaderboard, err := gh.db.GetLeaderboard(100)
+	leaderboard, _, err := gh.db.GetLeaderboard(models.LeaderboardQuery{
+		Sort:   models.LeaderboardSortWins,
+		Period: models.LeaderboardPeriodAllTime,
+		Limit:  100,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get leaderboard"})
 		return
@@ -24,6 +31,12 @@ func (gh *GameHandler) GetLeaderboard(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"leaderboard": leaderboard})
 }
 
+// GetLiveGames lists the games in progress, which can be watched with
+// spectate-game.
+func (gh *GameHandler) GetLiveGames(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"games": gh.gameService.ListLiveGames()})
+}
+
 func (gh *GameHandler) GetHealth(c *gin.Context) {
 	if err := gh.db.Ping(); err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "database": "disconnected"})
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"connect4/internal/models"
 	"connect4/internal/services"
 	"connect4/internal/utils"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,23 +21,57 @@ func NewHTTPHandler(leaderboardService *services.LeaderboardService) *HTTPHandle
 	}
 }
 
+// GetLeaderboard returns a page of the leaderboard for a period, and with
+// ?username= that player's own rank.
 func (h *HTTPHandler) GetLeaderboard(c *gin.Context) {
 	limitStr := c.DefaultQuery("limit", "100")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 || limit > 100 {
 		limit = 100
 	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	sortBy := models.LeaderboardSort(c.DefaultQuery("sort", string(models.LeaderboardSortWins)))
+	if sortBy != models.LeaderboardSortWins && sortBy != models.LeaderboardSortRating {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_SORT", "Sort must be wins or rating")
+		return
+	}
+	period := models.LeaderboardPeriod(c.DefaultQuery("period", string(models.LeaderboardPeriodAllTime)))
+	if !period.IsValid() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_PERIOD", "Period must be daily, weekly, monthly or all-time")
+		return
+	}
+	excludeBots, err := strconv.ParseBool(c.DefaultQuery("exclude_bots", "false"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_EXCLUDE_BOTS", "exclude_bots must be true or false")
+		return
+	}
 
-	leaderboard, err := h.leaderboardService.GetLeaderboard(limit)
+	q := models.LeaderboardQuery{Sort: sortBy, Period: period, ExcludeBots: excludeBots, Limit: limit, Offset: offset}
+	leaderboard, total, err := h.leaderboardService.GetLeaderboard(q)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "LEADERBOARD_ERROR", "Failed to fetch leaderboard")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, gin.H{
+	response := gin.H{
 		"leaderboard": leaderboard,
-		"total":       len(leaderboard),
-	})
+		"total":       total,
+		"period":      period,
+	}
+	if username := c.Query("username"); username != "" {
+		me, err := h.leaderboardService.GetRank(q, username)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "LEADERBOARD_ERROR", "Failed to fetch rank")
+			return
+		}
+		response["me"] = me
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, response)
 }
 
 func (h *HTTPHandler) GetPlayerStats(c *gin.Context) {
@@ -60,3 +96,146 @@ func (h *HTTPHandler) GetPlayerStats(c *gin.Context) {
 		"player": player,
 	})
 }
+
+func (h *HTTPHandler) GetRatingHistory(c *gin.Context) {
+	username := c.Param("username")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	history, err := h.leaderboardService.GetRatingHistory(username, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "RATING_ERROR", "Failed to fetch rating history")
+		return
+	}
+	if history == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "PLAYER_NOT_FOUND", "Player not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"history": history,
+	})
+}
+
+// GetGameHistory lists the player's games, newest first, a page at a time.
+// Pass the returned next_cursor as cursor to get the next page.
+func (h *HTTPHandler) GetGameHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	filter := models.GameHistoryFilter{
+		Result:   models.GameResult(c.Query("result")),
+		Opponent: c.Query("opponent"),
+		Status:   models.GameStatus(c.Query("status")),
+		Limit:    limit,
+	}
+
+	switch filter.Result {
+	case "", models.GameResultWin, models.GameResultLoss, models.GameResultDraw, models.GameResultOngoing:
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_RESULT", "Result must be win, loss, draw or ongoing")
+		return
+	}
+	switch filter.Status {
+	case "", models.GameStatusActive, models.GameStatusCompleted, models.GameStatusForfeited, models.GameStatusDraw, models.GameStatusTimeout, models.GameStatusResigned:
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_STATUS", "Unknown game status")
+		return
+	}
+	if vsBot := c.Query("vs_bot"); vsBot != "" {
+		b, err := strconv.ParseBool(vsBot)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_VS_BOT", "vs_bot must be true or false")
+			return
+		}
+		filter.VsBot = &b
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseHistoryDate(from, false)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_DATE", "from must be a date or RFC 3339 time")
+			return
+		}
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseHistoryDate(to, true)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_DATE", "to must be a date or RFC 3339 time")
+			return
+		}
+		filter.To = &t
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		filter.After, err = models.ParseGameCursor(cursor)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_CURSOR", "Invalid cursor")
+			return
+		}
+	}
+
+	games, next, err := h.leaderboardService.GetGameHistory(c.Param("username"), filter)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "HISTORY_ERROR", "Failed to fetch game history")
+		return
+	}
+	if games == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "PLAYER_NOT_FOUND", "Player not found")
+		return
+	}
+
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.String()
+	}
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"games":       games,
+		"next_cursor": nextCursor,
+	})
+}
+
+// GetHeadToHead compares two players over their games against each other.
+func (h *HTTPHandler) GetHeadToHead(c *gin.Context) {
+	a, b := c.Param("a"), c.Param("b")
+	if a == b {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_USERNAME", "Pick two different players")
+		return
+	}
+	recent, err := strconv.Atoi(c.DefaultQuery("recent", "10"))
+	if err != nil || recent <= 0 || recent > 50 {
+		recent = 10
+	}
+
+	headToHead, err := h.leaderboardService.GetHeadToHead(a, b, recent)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "HEAD_TO_HEAD_ERROR", "Failed to fetch head-to-head")
+		return
+	}
+	if headToHead == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "PLAYER_NOT_FOUND", "Player not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"head_to_head": headToHead,
+	})
+}
+
+// parseHistoryDate reads an RFC 3339 time or a YYYY-MM-DD date. A date used
+// as the end of a range includes the whole day.
+func parseHistoryDate(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"connect4/internal/services"
+	"connect4/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ReplayHandler struct {
+	replayService *services.ReplayService
+}
+
+func NewReplayHandler(replayService *services.ReplayService) *ReplayHandler {
+	return &ReplayHandler{replayService: replayService}
+}
+
+// GetGame returns a finished or ongoing game with its players, result,
+// timestamps and moves in order.
+func (h *ReplayHandler) GetGame(c *gin.Context) {
+	gameID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_GAME_ID", "Game ID must be a UUID")
+		return
+	}
+
+	game, err := h.replayService.GetGame(gameID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "GAME_ERROR", "Failed to fetch game")
+		return
+	}
+	if game == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "GAME_NOT_FOUND", "Game not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"game": game,
+	})
+}
+
+// GetPosition returns the board after move N of a game, given as ?move=N.
+// Without it the final position is returned.
+func (h *ReplayHandler) GetPosition(c *gin.Context) {
+	gameID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_GAME_ID", "Game ID must be a UUID")
+		return
+	}
+	move := -1
+	if moveStr := c.Query("move"); moveStr != "" {
+		move, err = strconv.Atoi(moveStr)
+		if err != nil || move < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_MOVE", "Move must be a non-negative number")
+			return
+		}
+	}
+
+	position, err := h.replayService.GetPosition(gameID, move)
+	if errors.Is(err, services.ErrMoveOutOfRange) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_MOVE", "Move is past the end of the game")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "GAME_ERROR", "Failed to fetch game")
+		return
+	}
+	if position == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "GAME_NOT_FOUND", "Game not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"position": position,
+	})
+}
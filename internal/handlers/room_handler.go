@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"connect4/internal/middleware"
+	"connect4/internal/services"
+	"connect4/internal/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomHandler struct {
+	roomService        *services.RoomService
+	matchmakingService *services.MatchmakingService
+}
+
+func NewRoomHandler(roomService *services.RoomService, matchmakingService *services.MatchmakingService) *RoomHandler {
+	return &RoomHandler{roomService: roomService, matchmakingService: matchmakingService}
+}
+
+// CreateRoom opens a room for the signed-in player to share. The host then
+// sends join-room with the returned code over the WebSocket to wait in the
+// lobby.
+func (h *RoomHandler) CreateRoom(c *gin.Context) {
+	room, err := h.roomService.CreateRoom(middleware.CurrentPlayer(c).Username, "")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "ROOM_ERROR", "Failed to create room")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, gin.H{
+		"room": room,
+	})
+}
+
+func (h *RoomHandler) GetRoom(c *gin.Context) {
+	room := h.roomService.GetRoom(c.Param("code"))
+	if room == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "ROOM_NOT_FOUND", "Room not found or expired")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"room": room,
+	})
+}
+
+// JoinRoom starts the game in a room whose host is waiting in the lobby.
+// The game-started message goes to each player's WebSocket, if connected.
+// Hosts enter their own lobby over the WebSocket, since they need a
+// connection to wait on.
+func (h *RoomHandler) JoinRoom(c *gin.Context) {
+	username := middleware.CurrentPlayer(c).Username
+	if room := h.roomService.GetRoom(c.Param("code")); room != nil && room.Host == username {
+		utils.ErrorResponse(c, http.StatusConflict, "ROOM_HOST", "Send join-room over the WebSocket to wait in your own room")
+		return
+	}
+
+	h.matchmakingService.LeaveQueue(username)
+	room, err := h.roomService.JoinRoom(c.Param("code"), username, "")
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrRoomNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "ROOM_NOT_FOUND", "Room not found or expired")
+		return
+	case errors.Is(err, services.ErrHostNotInRoom):
+		utils.ErrorResponse(c, http.StatusConflict, "HOST_NOT_IN_ROOM", err.Error())
+		return
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "ROOM_ERROR", "Failed to join room")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"room": room,
+	})
+}
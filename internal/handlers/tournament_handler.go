@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"connect4/internal/middleware"
+	"connect4/internal/models"
+	"connect4/internal/services"
+	"connect4/internal/tournaments"
+	"connect4/internal/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TournamentHandler struct {
+	tournamentService *services.TournamentService
+}
+
+func NewTournamentHandler(tournamentService *services.TournamentService) *TournamentHandler {
+	return &TournamentHandler{tournamentService: tournamentService}
+}
+
+func (h *TournamentHandler) ListTournaments(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	status := tournaments.Status(c.Query("status"))
+	switch status {
+	case "", tournaments.StatusRegistering, tournaments.StatusRunning, tournaments.StatusFinished:
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_STATUS", "Status must be registering, running or finished")
+		return
+	}
+
+	list, err := h.tournamentService.List(status, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "TOURNAMENT_ERROR", "Failed to list tournaments")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"tournaments": list,
+	})
+}
+
+func (h *TournamentHandler) CreateTournament(c *gin.Context) {
+	var payload models.CreateTournamentPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_TOURNAMENT", "Name must be 3-100 characters")
+		return
+	}
+	format, err := tournaments.ParseFormat(payload.Format)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_FORMAT", "Format must be swiss, round-robin or knockout")
+		return
+	}
+
+	tournament, err := h.tournamentService.Create(payload.Name, format, payload.Rounds, middleware.CurrentPlayer(c).Username)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "TOURNAMENT_ERROR", "Failed to create tournament")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, gin.H{
+		"tournament": tournament,
+	})
+}
+
+func (h *TournamentHandler) GetTournament(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_TOURNAMENT_ID", "Tournament ID must be a number")
+		return
+	}
+
+	summary, err := h.tournamentService.Get(id)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "TOURNAMENT_ERROR", "Failed to fetch tournament")
+		return
+	}
+	if summary == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "TOURNAMENT_NOT_FOUND", "Tournament not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, summary)
+}
+
+func (h *TournamentHandler) JoinTournament(c *gin.Context) {
+	h.playerAction(c, h.tournamentService.Join)
+}
+
+func (h *TournamentHandler) StartTournament(c *gin.Context) {
+	h.playerAction(c, h.tournamentService.Start)
+}
+
+// playerAction runs a tournament action on behalf of the signed-in player
+// and maps the tournament errors to HTTP statuses.
+func (h *TournamentHandler) playerAction(c *gin.Context, action func(tournamentID int, username string) error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "INVALID_TOURNAMENT_ID", "Tournament ID must be a number")
+		return
+	}
+
+	err = action(id, middleware.CurrentPlayer(c).Username)
+	switch {
+	case err == nil:
+	case errors.Is(err, tournaments.ErrNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "TOURNAMENT_NOT_FOUND", err.Error())
+		return
+	case errors.Is(err, tournaments.ErrNotCreator):
+		utils.ErrorResponse(c, http.StatusForbidden, "NOT_CREATOR", err.Error())
+		return
+	case errors.Is(err, tournaments.ErrNotRegistering), errors.Is(err, tournaments.ErrAlreadyJoined), errors.Is(err, tournaments.ErrNotEnoughPlayers):
+		utils.ErrorResponse(c, http.StatusConflict, "TOURNAMENT_CONFLICT", err.Error())
+		return
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "TOURNAMENT_ERROR", "Failed to update tournament")
+		return
+	}
+
+	summary, err := h.tournamentService.Get(id)
+	if err != nil || summary == nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "TOURNAMENT_ERROR", "Failed to fetch tournament")
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, summary)
+}
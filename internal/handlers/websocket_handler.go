@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"connect4/internal/bot"
+	"connect4/internal/middleware"
 	"connect4/internal/models"
 	"connect4/internal/services"
+	"connect4/internal/tournaments"
+	"connect4/internal/utils"
 	"connect4/pkg/logger"
 	"encoding/json"
 	"net/http"
@@ -21,49 +25,101 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// wsConn is a WebSocket connection that may be written to from several
+// goroutines at once: read loops, game timers and broadcasts all send to
+// the same players. gorilla/websocket allows only one writer at a time.
+type wsConn struct {
+	*websocket.Conn
+	writeMutex sync.Mutex
+}
+
+func (c *wsConn) WriteJSON(v interface{}) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
 type WSHandler struct {
 	matchmakingService  *services.MatchmakingService
 	gameService         *services.GameService
 	reconnectionService *services.ReconnectionService
-	connections         map[string]*websocket.Conn
+	roomService         *services.RoomService
+	challengeService    *services.ChallengeService
+	tournamentService   *services.TournamentService
+	chatService         *services.ChatService
+	authService         *services.AuthService
+	connections         map[string]*wsConn
 	playerGames         map[string]uuid.UUID
-	connMutex           sync.RWMutex
+	// spectators holds the connections watching each game, with the name
+	// each chats under, if any.
+	spectators map[uuid.UUID]map[*wsConn]string
+	connMutex  sync.RWMutex
 }
 
-func NewWSHandler(matchmaking *services.MatchmakingService, game *services.GameService, reconnection *services.ReconnectionService) *WSHandler {
+func NewWSHandler(matchmaking *services.MatchmakingService, game *services.GameService, reconnection *services.ReconnectionService, rooms *services.RoomService, challenges *services.ChallengeService, tournamentService *services.TournamentService, chat *services.ChatService, auth *services.AuthService) *WSHandler {
 	handler := &WSHandler{
 		matchmakingService:  matchmaking,
 		gameService:         game,
 		reconnectionService: reconnection,
-		connections:         make(map[string]*websocket.Conn),
+		roomService:         rooms,
+		challengeService:    challenges,
+		tournamentService:   tournamentService,
+		chatService:         chat,
+		authService:         auth,
+		connections:         make(map[string]*wsConn),
 		playerGames:         make(map[string]uuid.UUID),
+		spectators:          make(map[uuid.UUID]map[*wsConn]string),
 	}
 
 	matchmaking.SetMatchCallback(handler.handlePlayerMatch)
 	matchmaking.SetBotCallback(handler.handleBotMatch)
 	reconnection.SetForfeitCallback(handler.handleForfeit)
 	reconnection.SetReconnectCallback(handler.handleReconnect)
+	rooms.SetStartCallback(handler.handlePlayerMatch)
+	rooms.SetExpireCallback(handler.handleRoomExpired)
+	challenges.SetStartCallback(handler.handlePlayerMatch)
+	challenges.SetExpireCallback(handler.handleChallengeExpired)
+	tournamentService.SetGameStartCallback(handler.handleTournamentGame)
+	game.SetTimeoutCallback(handler.sendGameOver)
+	game.SetIdleWarningCallback(handler.handleIdleWarning)
+	game.SetIdleForfeitCallback(handler.sendGameOver)
 
 	return handler
 }
 
+// HandleWebSocket plays for the player signed in with the session token,
+// if any. A connection without a token may only spectate.
 func (h *WSHandler) HandleWebSocket(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	var username string
+	if token := middleware.BearerToken(c.Request); token != "" {
+		player, err := h.authService.Authenticate(token)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "AUTH_ERROR", "Failed to check session")
+			return
+		}
+		if player == nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "UNAUTHORIZED", "Session is invalid or has expired")
+			return
+		}
+		username = player.Username
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		logger.Log.Error("Failed to upgrade connection", zap.Error(err))
 		return
 	}
+	conn := &wsConn{Conn: ws}
 
 	socketID := uuid.New().String()
 	defer conn.Close()
 
-	var username string
-
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
+			h.stopSpectatingAll(conn)
 			if username != "" {
-				h.handleDisconnection(username)
+				h.handleDisconnection(conn, username)
 			}
 			break
 		}
@@ -73,45 +129,257 @@ func (h *WSHandler) HandleWebSocket(c *gin.Context) {
 			h.sendError(conn, "Invalid message format")
 			continue
 		}
+		if username == "" && wsMsg.Type != models.WSSpectateGame && wsMsg.Type != models.WSStopSpectating {
+			h.sendError(conn, "Sign in or play as a guest first")
+			continue
+		}
 
 		switch wsMsg.Type {
 		case models.WSJoinMatchmaking:
-			username = h.handleJoinMatchmaking(conn, socketID, wsMsg.Payload)
+			h.handleJoinMatchmaking(conn, username, socketID, wsMsg.Payload)
 		case models.WSMakeMove:
 			h.handleMakeMove(conn, username, wsMsg.Payload)
 		case models.WSReconnectGame:
 			h.handleReconnectGame(conn, username, wsMsg.Payload)
+		case models.WSCreateRoom:
+			h.handleCreateRoom(conn, username, socketID)
+		case models.WSJoinRoom:
+			h.handleJoinRoom(conn, username, socketID, wsMsg.Payload)
+		case models.WSChallengePlayer:
+			h.handleChallengePlayer(conn, username, socketID, wsMsg.Payload)
+		case models.WSAcceptChallenge:
+			h.handleAcceptChallenge(conn, username, socketID, wsMsg.Payload)
+		case models.WSDeclineChallenge:
+			h.handleDeclineChallenge(conn, username, wsMsg.Payload)
+		case models.WSResign:
+			h.handleResign(conn, username, wsMsg.Payload)
+		case models.WSOfferDraw:
+			h.handleOfferDraw(conn, username, wsMsg.Payload)
+		case models.WSRespondDraw:
+			h.handleRespondDraw(conn, username, wsMsg.Payload)
+		case models.WSRequestRematch:
+			h.handleRequestRematch(conn, username, wsMsg.Payload)
+		case models.WSAcceptRematch:
+			h.handleAcceptRematch(conn, username, wsMsg.Payload)
+		case models.WSSpectateGame:
+			h.handleSpectateGame(conn, username, wsMsg.Payload)
+		case models.WSStopSpectating:
+			h.handleStopSpectating(conn, wsMsg.Payload)
+		case models.WSChatMessage:
+			h.handleChatMessage(conn, username, wsMsg.Payload)
+		case models.WSMuteOpponent:
+			h.handleMuteOpponent(conn, username, wsMsg.Payload, true)
+		case models.WSUnmuteOpponent:
+			h.handleMuteOpponent(conn, username, wsMsg.Payload, false)
 		}
 	}
 }
 
-func (h *WSHandler) handleJoinMatchmaking(conn *websocket.Conn, socketID string, payload interface{}) string {
+func (h *WSHandler) handleJoinMatchmaking(conn *wsConn, username, socketID string, payload interface{}) {
 	data, _ := json.Marshal(payload)
 	var joinPayload models.JoinMatchmakingPayload
-	if err := json.Unmarshal(data, &joinPayload); err != nil || joinPayload.Username == "" {
-		h.sendError(conn, "Invalid username")
-		return ""
+	if err := json.Unmarshal(data, &joinPayload); err != nil {
+		h.sendError(conn, "Invalid matchmaking payload")
+		return
+	}
+
+	difficulty, err := bot.ParseDifficulty(joinPayload.BotDifficulty)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
 	}
 
-	username := joinPayload.Username
 	h.connMutex.Lock()
 	h.connections[username] = conn
 	h.connMutex.Unlock()
+	h.roomService.LeaveLobby(username)
 
-	if err := h.matchmakingService.JoinQueue(username, socketID); err != nil {
+	if err := h.matchmakingService.JoinQueue(username, socketID, string(difficulty), joinPayload.TimeControl); err != nil {
 		h.sendError(conn, err.Error())
-		return username
+		return
 	}
 
 	h.sendMessage(conn, models.WSMessage{
 		Type:    models.WSMatchmakingStatus,
 		Payload: map[string]interface{}{"status": "searching", "message": "Looking for opponent..."},
 	})
+}
+
+func (h *WSHandler) handleCreateRoom(conn *wsConn, username, socketID string) {
+	h.connMutex.Lock()
+	h.connections[username] = conn
+	h.connMutex.Unlock()
+	h.matchmakingService.LeaveQueue(username)
+
+	room, err := h.roomService.CreateRoom(username, socketID)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+
+	h.sendMessage(conn, models.WSMessage{Type: models.WSRoomCreated, Payload: room})
+}
+
+func (h *WSHandler) handleJoinRoom(conn *wsConn, username, socketID string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var joinPayload models.JoinRoomPayload
+	if err := json.Unmarshal(data, &joinPayload); err != nil || joinPayload.Code == "" {
+		h.sendError(conn, "Invalid room payload")
+		return
+	}
+
+	h.connMutex.Lock()
+	h.connections[username] = conn
+	h.connMutex.Unlock()
+	h.matchmakingService.LeaveQueue(username)
+
+	room, err := h.roomService.JoinRoom(joinPayload.Code, username, socketID)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+
+	// A guest's join starts the game, announced by game-started; the host
+	// joining their own room just enters the lobby.
+	if room.Host == username {
+		h.sendMessage(conn, models.WSMessage{Type: models.WSRoomCreated, Payload: room})
+	}
+}
+
+func (h *WSHandler) handleRoomExpired(room *models.Room) {
+	h.connMutex.RLock()
+	conn := h.connections[room.Host]
+	h.connMutex.RUnlock()
+
+	if conn != nil && room.HostInLobby {
+		h.sendMessage(conn, models.WSMessage{
+			Type:    models.WSRoomExpired,
+			Payload: map[string]interface{}{"code": room.Code},
+		})
+	}
+}
+
+func (h *WSHandler) handleChallengePlayer(conn *wsConn, username, socketID string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var challengePayload models.ChallengePlayerPayload
+	if err := json.Unmarshal(data, &challengePayload); err != nil || challengePayload.Opponent == "" {
+		h.sendError(conn, "Invalid challenge payload")
+		return
+	}
+
+	h.connMutex.Lock()
+	h.connections[username] = conn
+	opponentConn := h.connections[challengePayload.Opponent]
+	h.connMutex.Unlock()
 
-	return username
+	if opponentConn == nil {
+		h.sendError(conn, "Player is not online")
+		return
+	}
+	if h.inActiveGame(username) {
+		h.sendError(conn, "You are already in a game")
+		return
+	}
+	if h.inActiveGame(challengePayload.Opponent) {
+		h.sendError(conn, "Player is already in a game")
+		return
+	}
+
+	challenge, err := h.challengeService.Challenge(username, socketID, challengePayload.Opponent)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+
+	h.sendMessage(conn, models.WSMessage{Type: models.WSChallengeSent, Payload: challenge})
+	h.sendMessage(opponentConn, models.WSMessage{Type: models.WSChallengeReceived, Payload: challenge})
+}
+
+func (h *WSHandler) handleAcceptChallenge(conn *wsConn, username, socketID string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var response models.ChallengeResponsePayload
+	if err := json.Unmarshal(data, &response); err != nil {
+		h.sendError(conn, "Invalid challenge payload")
+		return
+	}
+	if h.inActiveGame(username) {
+		h.sendError(conn, "You are already in a game")
+		return
+	}
+
+	// The game-started messages are sent by handlePlayerMatch.
+	if err := h.challengeService.Accept(response.ChallengeID, username, socketID, h.inActiveGame); err != nil {
+		h.sendError(conn, err.Error())
+	}
+}
+
+func (h *WSHandler) handleDeclineChallenge(conn *wsConn, username string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var response models.ChallengeResponsePayload
+	if err := json.Unmarshal(data, &response); err != nil {
+		h.sendError(conn, "Invalid challenge payload")
+		return
+	}
+
+	challenge, err := h.challengeService.Decline(response.ChallengeID, username)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+
+	h.connMutex.RLock()
+	challengerConn := h.connections[challenge.Challenger]
+	h.connMutex.RUnlock()
+	if challengerConn != nil {
+		h.sendMessage(challengerConn, models.WSMessage{Type: models.WSChallengeDeclined, Payload: challenge})
+	}
+}
+
+func (h *WSHandler) handleChallengeExpired(challenge *models.Challenge) {
+	h.connMutex.RLock()
+	challengerConn := h.connections[challenge.Challenger]
+	opponentConn := h.connections[challenge.Opponent]
+	h.connMutex.RUnlock()
+
+	for _, conn := range []*wsConn{challengerConn, opponentConn} {
+		if conn != nil {
+			h.sendMessage(conn, models.WSMessage{Type: models.WSChallengeExpired, Payload: challenge})
+		}
+	}
+}
+
+// cancelChallenges withdraws username's pending challenges, in both
+// directions, and tells the other side of each.
+func (h *WSHandler) cancelChallenges(username string) {
+	for _, challenge := range h.challengeService.CancelFor(username) {
+		other := challenge.Challenger
+		if other == username {
+			other = challenge.Opponent
+		}
+		h.connMutex.RLock()
+		conn := h.connections[other]
+		h.connMutex.RUnlock()
+		if conn != nil {
+			h.sendMessage(conn, models.WSMessage{Type: models.WSChallengeCancelled, Payload: challenge})
+		}
+	}
+}
+
+func (h *WSHandler) inActiveGame(username string) bool {
+	h.connMutex.RLock()
+	gameID, hasGame := h.playerGames[username]
+	h.connMutex.RUnlock()
+	if !hasGame {
+		return false
+	}
+	game, err := h.gameService.GetGame(gameID)
+	return err == nil && game.Status == models.GameStatusActive
 }
 
 func (h *WSHandler) handlePlayerMatch(player1, player2 *models.WaitingPlayer, gameState *models.GameState) {
+	h.cancelChallenges(player1.Username)
+	h.cancelChallenges(player2.Username)
+
 	h.connMutex.Lock()
 	conn1 := h.connections[player1.Username]
 	conn2 := h.connections[player2.Username]
@@ -128,6 +396,8 @@ func (h *WSHandler) handlePlayerMatch(player1, player2 *models.WaitingPlayer, ga
 				YourColor:   models.ColorRed,
 				CurrentTurn: models.ColorRed,
 				IsBot:       false,
+				TimeControl: gameState.TimeControl.String(),
+				Clocks:      h.gameService.GetClocks(gameState.GameID),
 			},
 		})
 	}
@@ -141,12 +411,16 @@ func (h *WSHandler) handlePlayerMatch(player1, player2 *models.WaitingPlayer, ga
 				YourColor:   models.ColorYellow,
 				CurrentTurn: models.ColorRed,
 				IsBot:       false,
+				TimeControl: gameState.TimeControl.String(),
+				Clocks:      h.gameService.GetClocks(gameState.GameID),
 			},
 		})
 	}
 }
 
 func (h *WSHandler) handleBotMatch(player *models.WaitingPlayer, gameState *models.GameState) {
+	h.cancelChallenges(player.Username)
+
 	h.connMutex.Lock()
 	conn := h.connections[player.Username]
 	h.playerGames[player.Username] = gameState.GameID
@@ -156,17 +430,60 @@ func (h *WSHandler) handleBotMatch(player *models.WaitingPlayer, gameState *mode
 		h.sendMessage(conn, models.WSMessage{
 			Type: models.WSGameStarted,
 			Payload: models.GameStartedPayload{
-				GameID:      gameState.GameID,
-				Opponent:    "Bot",
-				YourColor:   models.ColorRed,
-				CurrentTurn: models.ColorRed,
-				IsBot:       true,
+				GameID:        gameState.GameID,
+				Opponent:      "Bot",
+				YourColor:     models.ColorRed,
+				CurrentTurn:   models.ColorRed,
+				IsBot:         true,
+				BotDifficulty: gameState.Player2.Difficulty,
+				TimeControl:   gameState.TimeControl.String(),
+				Clocks:        h.gameService.GetClocks(gameState.GameID),
 			},
 		})
 	}
 }
 
-func (h *WSHandler) handleMakeMove(conn *websocket.Conn, username string, payload interface{}) {
+// handleTournamentGame tells both players their tournament game has started.
+// A player who is not connected is treated as disconnected from the game,
+// so they can join it with reconnect-game or forfeit it when time runs out.
+func (h *WSHandler) handleTournamentGame(tournament *tournaments.Tournament, pairing tournaments.Pairing, gameState *models.GameState) {
+	h.cancelChallenges(gameState.Player1.Username)
+	h.cancelChallenges(gameState.Player2.Username)
+
+	players := []models.PlayerInfo{gameState.Player1, gameState.Player2}
+	for i, player := range players {
+		opponent := players[1-i]
+		h.matchmakingService.LeaveQueue(player.Username)
+
+		h.connMutex.Lock()
+		conn := h.connections[player.Username]
+		h.playerGames[player.Username] = gameState.GameID
+		h.connMutex.Unlock()
+
+		if conn == nil {
+			h.reconnectionService.TrackDisconnection(player.Username, player.ID, gameState.GameID)
+			continue
+		}
+		h.sendMessage(conn, models.WSMessage{
+			Type: models.WSTournamentGameStarted,
+			Payload: models.TournamentGameStartedPayload{
+				TournamentID: tournament.ID,
+				Round:        pairing.Round,
+				GameStartedPayload: models.GameStartedPayload{
+					GameID:      gameState.GameID,
+					Opponent:    opponent.Username,
+					YourColor:   player.Color,
+					CurrentTurn: models.ColorRed,
+					IsBot:       false,
+					TimeControl: gameState.TimeControl.String(),
+					Clocks:      h.gameService.GetClocks(gameState.GameID),
+				},
+			},
+		})
+	}
+}
+
+func (h *WSHandler) handleMakeMove(conn *wsConn, username string, payload interface{}) {
 	data, _ := json.Marshal(payload)
 	var movePayload models.MakeMovePayload
 	if err := json.Unmarshal(data, &movePayload); err != nil {
@@ -208,28 +525,359 @@ func (h *WSHandler) handleMakeMove(conn *websocket.Conn, username string, payloa
 	if opponentConn != nil {
 		h.sendMessage(opponentConn, models.WSMessage{Type: models.WSOpponentMoved, Payload: move})
 	}
+	h.broadcastToSpectators(game.GameID, models.WSMessage{Type: models.WSOpponentMoved, Payload: move})
 
 	if gameOver != nil {
 		h.sendMessage(conn, models.WSMessage{Type: models.WSGameOver, Payload: gameOver})
 		if opponentConn != nil && !game.Player2.IsBot {
 			h.sendMessage(opponentConn, models.WSMessage{Type: models.WSGameOver, Payload: gameOver})
 		}
+		h.endSpectating(game.GameID, gameOver)
 		return
 	}
 
 	if game.Player2.IsBot && move.NextTurn == models.ColorYellow {
-		time.Sleep(500 * time.Millisecond)
+		// A bot that cannot move forfeits, so botMove may be nil with the
+		// game over.
 		botMove, botGameOver, err := h.gameService.MakeBotMove(movePayload.GameID)
-		if err == nil {
+		if err != nil {
+			logger.Log.Warn("Bot move not made", zap.String("game_id", game.GameID.String()), zap.Error(err))
+			return
+		}
+		if botMove != nil {
 			h.sendMessage(conn, models.WSMessage{Type: models.WSOpponentMoved, Payload: botMove})
-			if botGameOver != nil {
-				h.sendMessage(conn, models.WSMessage{Type: models.WSGameOver, Payload: botGameOver})
-			}
+			h.broadcastToSpectators(game.GameID, models.WSMessage{Type: models.WSOpponentMoved, Payload: botMove})
+		}
+		if botGameOver != nil {
+			h.sendMessage(conn, models.WSMessage{Type: models.WSGameOver, Payload: botGameOver})
+			h.endSpectating(game.GameID, botGameOver)
 		}
 	}
 }
 
-func (h *WSHandler) handleReconnectGame(conn *websocket.Conn, username string, payload interface{}) {
+// sendGameOver sends the game over to both players, as when one runs out
+// of time, forfeits by not moving or resigns.
+func (h *WSHandler) sendGameOver(game *models.GameState, gameOver *models.GameOverPayload) {
+	h.connMutex.RLock()
+	conn1 := h.connections[game.Player1.Username]
+	conn2 := h.connections[game.Player2.Username]
+	h.connMutex.RUnlock()
+
+	if conn1 != nil {
+		h.sendMessage(conn1, models.WSMessage{Type: models.WSGameOver, Payload: gameOver})
+	}
+	if conn2 != nil && !game.Player2.IsBot {
+		h.sendMessage(conn2, models.WSMessage{Type: models.WSGameOver, Payload: gameOver})
+	}
+	h.endSpectating(game.GameID, gameOver)
+}
+
+// handleSpectateGame subscribes the connection to a game in progress and
+// sends it the board so far. A signed-in spectator chats under their own
+// username.
+func (h *WSHandler) handleSpectateGame(conn *wsConn, username string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var action models.GameActionPayload
+	if err := json.Unmarshal(data, &action); err != nil {
+		h.sendError(conn, "Invalid game payload")
+		return
+	}
+
+	// Subscribe before taking the snapshot so no move falls between them.
+	// A move made in between may arrive before spectate-started, whose
+	// board already includes it.
+	h.connMutex.Lock()
+	if h.spectators[action.GameID] == nil {
+		h.spectators[action.GameID] = make(map[*wsConn]string)
+	}
+	h.spectators[action.GameID][conn] = username
+	h.connMutex.Unlock()
+
+	game, err := h.gameService.GetGameSnapshot(action.GameID)
+	if err != nil || game.Status != models.GameStatusActive {
+		h.stopSpectating(conn, action.GameID)
+		h.sendError(conn, "Game not found or already over")
+		return
+	}
+	if username != "" && (username == game.Player1.Username || username == game.Player2.Username) {
+		h.stopSpectating(conn, action.GameID)
+		h.sendError(conn, "You are playing in this game")
+		return
+	}
+
+	h.sendMessage(conn, models.WSMessage{
+		Type: models.WSSpectateStarted,
+		Payload: models.SpectateStartedPayload{
+			GameID:      game.GameID,
+			Player1:     game.Player1,
+			Player2:     game.Player2,
+			Board:       game.Board,
+			CurrentTurn: game.CurrentTurn,
+			MoveCount:   game.MoveCount,
+			TimeControl: game.TimeControl.String(),
+			Clocks:      h.gameService.GetClocks(game.GameID),
+		},
+	})
+}
+
+func (h *WSHandler) handleStopSpectating(conn *wsConn, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var action models.GameActionPayload
+	if err := json.Unmarshal(data, &action); err != nil {
+		h.sendError(conn, "Invalid game payload")
+		return
+	}
+	h.stopSpectating(conn, action.GameID)
+}
+
+func (h *WSHandler) stopSpectating(conn *wsConn, gameID uuid.UUID) {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	delete(h.spectators[gameID], conn)
+	if len(h.spectators[gameID]) == 0 {
+		delete(h.spectators, gameID)
+	}
+}
+
+// stopSpectatingAll unsubscribes a closed connection from every game it was
+// watching.
+func (h *WSHandler) stopSpectatingAll(conn *wsConn) {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	for gameID, conns := range h.spectators {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(h.spectators, gameID)
+		}
+	}
+}
+
+func (h *WSHandler) broadcastToSpectators(gameID uuid.UUID, msg models.WSMessage) {
+	h.connMutex.RLock()
+	conns := make([]*wsConn, 0, len(h.spectators[gameID]))
+	for conn := range h.spectators[gameID] {
+		conns = append(conns, conn)
+	}
+	h.connMutex.RUnlock()
+
+	for _, conn := range conns {
+		h.sendMessage(conn, msg)
+	}
+}
+
+// endSpectating sends the game over to the game's spectators and drops
+// them.
+func (h *WSHandler) endSpectating(gameID uuid.UUID, gameOver *models.GameOverPayload) {
+	h.broadcastToSpectators(gameID, models.WSMessage{Type: models.WSGameOver, Payload: gameOver})
+	h.connMutex.Lock()
+	delete(h.spectators, gameID)
+	h.connMutex.Unlock()
+}
+
+func (h *WSHandler) handleResign(conn *wsConn, username string, payload interface{}) {
+	game, player, _, ok := h.gameAction(conn, username, payload)
+	if !ok {
+		return
+	}
+	gameOver, err := h.gameService.Resign(game.GameID, player.ID)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+	h.sendGameOver(game, gameOver)
+}
+
+func (h *WSHandler) handleOfferDraw(conn *wsConn, username string, payload interface{}) {
+	game, player, opponent, ok := h.gameAction(conn, username, payload)
+	if !ok {
+		return
+	}
+	if err := h.gameService.OfferDraw(game.GameID, player.ID); err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+	h.sendToPlayer(opponent.Username, models.WSMessage{
+		Type:    models.WSDrawOffered,
+		Payload: models.GameOfferPayload{GameID: game.GameID, From: username},
+	})
+}
+
+func (h *WSHandler) handleRespondDraw(conn *wsConn, username string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var response models.RespondDrawPayload
+	if err := json.Unmarshal(data, &response); err != nil {
+		h.sendError(conn, "Invalid draw response payload")
+		return
+	}
+	game, player, opponent, ok := h.gameAction(conn, username, models.GameActionPayload{GameID: response.GameID})
+	if !ok {
+		return
+	}
+
+	gameOver, err := h.gameService.RespondDraw(game.GameID, player.ID, response.Accept)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+	if gameOver != nil {
+		h.sendGameOver(game, gameOver)
+		return
+	}
+	h.sendToPlayer(opponent.Username, models.WSMessage{
+		Type:    models.WSDrawDeclined,
+		Payload: models.GameOfferPayload{GameID: game.GameID, From: username},
+	})
+}
+
+func (h *WSHandler) handleRequestRematch(conn *wsConn, username string, payload interface{}) {
+	game, player, opponent, ok := h.gameAction(conn, username, payload)
+	if !ok {
+		return
+	}
+	if err := h.gameService.RequestRematch(game.GameID, player.ID); err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+	h.sendToPlayer(opponent.Username, models.WSMessage{
+		Type:    models.WSRematchRequested,
+		Payload: models.GameOfferPayload{GameID: game.GameID, From: username},
+	})
+}
+
+func (h *WSHandler) handleAcceptRematch(conn *wsConn, username string, payload interface{}) {
+	game, player, opponent, ok := h.gameAction(conn, username, payload)
+	if !ok {
+		return
+	}
+	if h.inActiveGame(username) {
+		h.sendError(conn, "You are already in a game")
+		return
+	}
+	if h.inActiveGame(opponent.Username) {
+		h.sendError(conn, "Player is already in a game")
+		return
+	}
+
+	rematch, err := h.gameService.AcceptRematch(game.GameID, player.ID)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+	h.handlePlayerMatch(
+		&models.WaitingPlayer{Username: rematch.Player1.Username, PlayerID: rematch.Player1.ID},
+		&models.WaitingPlayer{Username: rematch.Player2.Username, PlayerID: rematch.Player2.ID},
+		rematch,
+	)
+}
+
+// gameAction decodes a GameActionPayload and looks up the game with the
+// sender and their opponent in it, reporting any problem to the sender.
+func (h *WSHandler) gameAction(conn *wsConn, username string, payload interface{}) (*models.GameState, models.PlayerInfo, models.PlayerInfo, bool) {
+	data, _ := json.Marshal(payload)
+	var action models.GameActionPayload
+	if err := json.Unmarshal(data, &action); err != nil {
+		h.sendError(conn, "Invalid game payload")
+		return nil, models.PlayerInfo{}, models.PlayerInfo{}, false
+	}
+
+	game, err := h.gameService.GetGame(action.GameID)
+	if err != nil {
+		h.sendError(conn, "Game not found")
+		return nil, models.PlayerInfo{}, models.PlayerInfo{}, false
+	}
+	switch username {
+	case game.Player1.Username:
+		return game, game.Player1, game.Player2, true
+	case game.Player2.Username:
+		return game, game.Player2, game.Player1, true
+	}
+	h.sendError(conn, "You are not in this game")
+	return nil, models.PlayerInfo{}, models.PlayerInfo{}, false
+}
+
+func (h *WSHandler) sendToPlayer(username string, msg models.WSMessage) {
+	h.connMutex.RLock()
+	conn := h.connections[username]
+	h.connMutex.RUnlock()
+	if conn != nil {
+		h.sendMessage(conn, msg)
+	}
+}
+
+// handleChatMessage sends a chat message to the game's players, except one
+// who has muted the sender, and to its spectators.
+func (h *WSHandler) handleChatMessage(conn *wsConn, username string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var chat models.SendChatPayload
+	if err := json.Unmarshal(data, &chat); err != nil {
+		h.sendError(conn, "Invalid chat payload")
+		return
+	}
+	game, err := h.gameService.GetGame(chat.GameID)
+	if err != nil {
+		h.sendError(conn, "Game not found")
+		return
+	}
+
+	sender, isSpectator := username, false
+	if username == "" || (username != game.Player1.Username && username != game.Player2.Username) {
+		h.connMutex.RLock()
+		sender = h.spectators[chat.GameID][conn]
+		h.connMutex.RUnlock()
+		isSpectator = true
+	}
+	if sender == "" {
+		h.sendError(conn, "Spectate the game signed in to chat")
+		return
+	}
+
+	msg, err := h.chatService.Send(chat.GameID, sender, isSpectator, chat.Message)
+	if err != nil {
+		h.sendError(conn, err.Error())
+		return
+	}
+
+	for _, player := range []models.PlayerInfo{game.Player1, game.Player2} {
+		if player.IsBot {
+			continue
+		}
+		if !isSpectator && sender != player.Username && h.chatService.HasMutedOpponent(chat.GameID, player.Username) {
+			continue
+		}
+		h.sendToPlayer(player.Username, models.WSMessage{Type: models.WSChatMessage, Payload: msg})
+	}
+	h.broadcastToSpectators(chat.GameID, models.WSMessage{Type: models.WSChatMessage, Payload: msg})
+}
+
+// handleMuteOpponent hides, or shows again, the opponent's chat messages
+// from the player.
+func (h *WSHandler) handleMuteOpponent(conn *wsConn, username string, payload interface{}, muted bool) {
+	game, player, _, ok := h.gameAction(conn, username, payload)
+	if !ok {
+		return
+	}
+	h.chatService.SetMuted(game.GameID, player.Username, muted)
+}
+
+// handleIdleWarning tells a player who has not moved for a while how long
+// they have left before they forfeit.
+func (h *WSHandler) handleIdleWarning(game *models.GameState, player models.PlayerInfo, remaining time.Duration) {
+	h.connMutex.RLock()
+	conn := h.connections[player.Username]
+	h.connMutex.RUnlock()
+
+	if conn != nil {
+		h.sendMessage(conn, models.WSMessage{
+			Type: models.WSMoveTimeoutWarning,
+			Payload: models.MoveTimeoutWarningPayload{
+				GameID:           game.GameID,
+				SecondsRemaining: int(remaining.Seconds()),
+			},
+		})
+	}
+}
+
+func (h *WSHandler) handleReconnectGame(conn *wsConn, username string, payload interface{}) {
 	gameState, err := h.reconnectionService.HandleReconnection(username)
 	if err != nil || gameState == nil {
 		h.sendError(conn, "Failed to reconnect to game")
@@ -260,6 +908,8 @@ func (h *WSHandler) handleReconnectGame(conn *websocket.Conn, username string, p
 			"move_count":   gameState.MoveCount,
 			"your_color":   yourColor,
 			"opponent":     opponentName,
+			"time_control": gameState.TimeControl,
+			"clocks":       h.gameService.GetClocks(gameState.GameID),
 		},
 	})
 
@@ -278,42 +928,58 @@ func (h *WSHandler) handleReconnectGame(conn *websocket.Conn, username string, p
 	}
 }
 
-func (h *WSHandler) handleDisconnection(username string) {
+// handleDisconnection acts on a player's socket closing. Only the socket
+// the player last played from counts, so closing a second tab that was only
+// spectating leaves their game alone.
+func (h *WSHandler) handleDisconnection(conn *wsConn, username string) {
 	h.connMutex.Lock()
+	if h.connections[username] != conn {
+		h.connMutex.Unlock()
+		return
+	}
 	delete(h.connections, username)
 	gameID, hasGame := h.playerGames[username]
 	h.connMutex.Unlock()
 
-	if hasGame {
-		game, err := h.gameService.GetGame(gameID)
-		if err == nil && game.Status == models.GameStatusActive {
-			var playerID int
-			if game.Player1.Username == username {
-				playerID = game.Player1.ID
-			} else {
-				playerID = game.Player2.ID
-			}
-			h.reconnectionService.TrackDisconnection(username, playerID, gameID)
+	h.cancelChallenges(username)
 
-			// Notify opponent
-			opponentUsername := game.Player2.Username
-			if username == game.Player2.Username {
-				opponentUsername = game.Player1.Username
-			}
-			h.connMutex.RLock()
-			opponentConn := h.connections[opponentUsername]
-			h.connMutex.RUnlock()
-			if opponentConn != nil {
-				h.sendMessage(opponentConn, models.WSMessage{
-					Type: models.WSOpponentDisconnected,
-					Payload: map[string]interface{}{
-						"time_remaining": 30,
-					},
-				})
-			}
+	// playerGames keeps a player's last game after it ends, so only an
+	// active game means they are still playing rather than waiting.
+	var game *models.GameState
+	if hasGame {
+		if g, err := h.gameService.GetGame(gameID); err == nil && g.Status == models.GameStatusActive {
+			game = g
 		}
-	} else {
+	}
+	if game == nil {
 		h.matchmakingService.LeaveQueue(username)
+		h.roomService.LeaveLobby(username)
+		return
+	}
+
+	var playerID int
+	if game.Player1.Username == username {
+		playerID = game.Player1.ID
+	} else {
+		playerID = game.Player2.ID
+	}
+	h.reconnectionService.TrackDisconnection(username, playerID, gameID)
+
+	// Notify opponent
+	opponentUsername := game.Player2.Username
+	if username == game.Player2.Username {
+		opponentUsername = game.Player1.Username
+	}
+	h.connMutex.RLock()
+	opponentConn := h.connections[opponentUsername]
+	h.connMutex.RUnlock()
+	if opponentConn != nil {
+		h.sendMessage(opponentConn, models.WSMessage{
+			Type: models.WSOpponentDisconnected,
+			Payload: map[string]interface{}{
+				"time_remaining": 30,
+			},
+		})
 	}
 }
 
@@ -337,17 +1003,16 @@ func (h *WSHandler) handleForfeit(gameID uuid.UUID, playerID int) {
 	winnerConn := h.connections[winnerUsername]
 	h.connMutex.RUnlock()
 
+	gameOver := &models.GameOverPayload{
+		Winner:   &winnerUsername,
+		Reason:   "forfeit",
+		Board:    game.Board,
+		Duration: 30,
+	}
 	if winnerConn != nil {
-		h.sendMessage(winnerConn, models.WSMessage{
-			Type: models.WSGameOver,
-			Payload: models.GameOverPayload{
-				Winner:   &winnerUsername,
-				Reason:   "forfeit",
-				Board:    game.Board,
-				Duration: 30,
-			},
-		})
+		h.sendMessage(winnerConn, models.WSMessage{Type: models.WSGameOver, Payload: gameOver})
 	}
+	h.endSpectating(gameID, gameOver)
 
 	logger.Log.Info("Game forfeited due to disconnect", zap.String("loser", loserUsername), zap.String("winner", winnerUsername))
 }
@@ -358,13 +1023,13 @@ func (h *WSHandler) handleReconnect(player *models.DisconnectedPlayer, gameState
 	logger.Log.Info("Player reconnected successfully", zap.String("username", player.Username))
 }
 
-func (h *WSHandler) sendMessage(conn *websocket.Conn, msg models.WSMessage) {
+func (h *WSHandler) sendMessage(conn *wsConn, msg models.WSMessage) {
 	if err := conn.WriteJSON(msg); err != nil {
 		logger.Log.Error("Failed to send message", zap.Error(err))
 	}
 }
 
-func (h *WSHandler) sendError(conn *websocket.Conn, message string) {
+func (h *WSHandler) sendError(conn *wsConn, message string) {
 	h.sendMessage(conn, models.WSMessage{
 		Type:    models.WSError,
 		Payload: models.ErrorPayload{Message: message},
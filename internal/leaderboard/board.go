@@ -0,0 +1,134 @@
+// Package leaderboard keeps ranked leaderboards in memory so pages and
+// ranks can be read without aggregating every game.
+package leaderboard
+
+import (
+	"connect4/internal/models"
+	"math"
+	"time"
+)
+
+// Stats is a player's all-time record, with their games against people
+// counted separately for leaderboards that leave out the bot.
+type Stats struct {
+	ID               int
+	Username         string
+	Rating           float64
+	RatingDeviation  float64
+	CreatedAt        time.Time
+	GamesWon         int
+	GamesPlayed      int
+	HumanGamesWon    int
+	HumanGamesPlayed int
+}
+
+// Board is one leaderboard ordering. It is not safe for concurrent use.
+type Board struct {
+	sortBy      models.LeaderboardSort
+	excludeBots bool
+	list        *SkipList[models.LeaderboardEntry]
+	entries     map[int]models.LeaderboardEntry
+}
+
+func NewBoard(sortBy models.LeaderboardSort, excludeBots bool) *Board {
+	b := &Board{
+		sortBy:      sortBy,
+		excludeBots: excludeBots,
+		entries:     make(map[int]models.LeaderboardEntry),
+	}
+	b.list = NewSkipList(func(x, y models.LeaderboardEntry) bool {
+		if b.ahead(x, y) {
+			return true
+		}
+		return !b.ahead(y, x) && x.ID < y.ID
+	})
+	return b
+}
+
+// ahead reports whether x ranks strictly above y, matching the database
+// leaderboard's order.
+func (b *Board) ahead(x, y models.LeaderboardEntry) bool {
+	if b.sortBy == models.LeaderboardSortRating {
+		if x.Rating != y.Rating {
+			return x.Rating > y.Rating
+		}
+		return x.GamesPlayed > y.GamesPlayed
+	}
+	if x.GamesWon != y.GamesWon {
+		return x.GamesWon > y.GamesWon
+	}
+	if x.WinRate != y.WinRate {
+		return x.WinRate > y.WinRate
+	}
+	return x.GamesPlayed > y.GamesPlayed
+}
+
+// Update adds the player, moves them to their new place, or removes them if
+// they have no games that count.
+func (b *Board) Update(s Stats) {
+	if old, exists := b.entries[s.ID]; exists {
+		b.list.Delete(old)
+		delete(b.entries, s.ID)
+	}
+
+	won, played := s.GamesWon, s.GamesPlayed
+	if b.excludeBots {
+		won, played = s.HumanGamesWon, s.HumanGamesPlayed
+	}
+	if played == 0 {
+		return
+	}
+	entry := models.LeaderboardEntry{
+		ID:              s.ID,
+		Username:        s.Username,
+		GamesWon:        won,
+		GamesPlayed:     played,
+		WinRate:         math.Round(float64(won)/float64(played)*10000) / 100,
+		Rating:          s.Rating,
+		RatingDeviation: s.RatingDeviation,
+		CreatedAt:       s.CreatedAt,
+	}
+	b.list.Insert(entry)
+	b.entries[s.ID] = entry
+}
+
+func (b *Board) Len() int {
+	return b.list.Len()
+}
+
+// Page returns up to limit entries from offset, ranked so that tied
+// players share a rank.
+func (b *Board) Page(offset, limit int) []models.LeaderboardEntry {
+	page := make([]models.LeaderboardEntry, 0)
+	b.list.Range(offset, limit, func(i int, entry models.LeaderboardEntry) bool {
+		// Everyone before entry is ahead of it unless it ties with the
+		// one just before; only the first entry needs a search.
+		switch n := len(page); {
+		case n == 0:
+			entry.Rank = b.rankOf(entry)
+		case !b.ahead(page[n-1], entry):
+			entry.Rank = page[n-1].Rank
+		default:
+			entry.Rank = i + 1
+		}
+		page = append(page, entry)
+		return true
+	})
+	return page
+}
+
+// Rank returns the player's entry with their rank, or nil if they are not
+// on the board.
+func (b *Board) Rank(playerID int) *models.LeaderboardEntry {
+	entry, exists := b.entries[playerID]
+	if !exists {
+		return nil
+	}
+	entry.Rank = b.rankOf(entry)
+	return &entry
+}
+
+// rankOf is one more than the number of entries strictly ahead of entry.
+func (b *Board) rankOf(entry models.LeaderboardEntry) int {
+	return b.list.CountBefore(func(x models.LeaderboardEntry) bool { return b.ahead(x, entry) }) + 1
+}
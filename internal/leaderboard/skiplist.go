@@ -0,0 +1,152 @@
+package leaderboard
+
+import "math/rand/v2"
+
+const (
+	maxLevel = 32
+	// p is the chance a node rises another level.
+	p = 0.25
+)
+
+// SkipList is a sorted list with O(log n) insert, delete, lookup by index
+// and counting of the elements before a value. Each link records how many
+// elements it skips, which is what makes indexing cheap. Elements must be
+// distinct under less.
+type SkipList[T any] struct {
+	less   func(a, b T) bool
+	head   *node[T]
+	level  int
+	length int
+}
+
+type node[T any] struct {
+	value T
+	next  []*node[T]
+	// span[i] is how many elements next[i] moves forward.
+	span []int
+}
+
+func NewSkipList[T any](less func(a, b T) bool) *SkipList[T] {
+	return &SkipList[T]{
+		less:  less,
+		head:  &node[T]{next: make([]*node[T], maxLevel), span: make([]int, maxLevel)},
+		level: 1,
+	}
+}
+
+func (s *SkipList[T]) Len() int {
+	return s.length
+}
+
+func randomLevel() int {
+	level := 1
+	for level < maxLevel && rand.Float64() < p {
+		level++
+	}
+	return level
+}
+
+func (s *SkipList[T]) Insert(v T) {
+	var update [maxLevel]*node[T]
+	var rank [maxLevel]int
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		if i < s.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i] != nil && s.less(x.next[i].value, v) {
+			rank[i] += x.span[i]
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = s.head
+			update[i].span[i] = s.length
+		}
+		s.level = level
+	}
+
+	n := &node[T]{value: v, next: make([]*node[T], level), span: make([]int, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+		n.span[i] = update[i].span[i] - (rank[0] - rank[i])
+		update[i].span[i] = rank[0] - rank[i] + 1
+	}
+	for i := level; i < s.level; i++ {
+		update[i].span[i]++
+	}
+	s.length++
+}
+
+// Delete removes the element equal to v, reporting whether there was one.
+func (s *SkipList[T]) Delete(v T) bool {
+	var update [maxLevel]*node[T]
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && s.less(x.next[i].value, v) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	x = x.next[0]
+	if x == nil || s.less(v, x.value) {
+		return false
+	}
+
+	for i := 0; i < s.level; i++ {
+		if update[i].next[i] == x {
+			update[i].span[i] += x.span[i] - 1
+			update[i].next[i] = x.next[i]
+		} else {
+			update[i].span[i]--
+		}
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.length--
+	return true
+}
+
+// CountBefore returns how many elements satisfy before, which must hold for
+// a prefix of the list and not after it.
+func (s *SkipList[T]) CountBefore(before func(T) bool) int {
+	count := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && before(x.next[i].value) {
+			count += x.span[i]
+			x = x.next[i]
+		}
+	}
+	return count
+}
+
+// Range calls fn with up to limit elements starting at index offset, in
+// order, stopping early if fn returns false.
+func (s *SkipList[T]) Range(offset, limit int, fn func(i int, v T) bool) {
+	if offset < 0 || offset >= s.length || limit <= 0 {
+		return
+	}
+	// Walk to the element just before offset.
+	traversed := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && traversed+x.span[i] <= offset {
+			traversed += x.span[i]
+			x = x.next[i]
+		}
+	}
+	for x = x.next[0]; x != nil && limit > 0; x = x.next[0] {
+		if !fn(offset, x.value) {
+			return
+		}
+		offset++
+		limit--
+	}
+}
@@ -0,0 +1,137 @@
+package leaderboard
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func intLess(a, b int) bool { return a < b }
+
+// checkList compares s with want, which must be sorted: its length, every
+// index Range hands out, CountBefore at each boundary, and the span on every
+// link.
+func checkList(t *testing.T, s *SkipList[int], want []int) {
+	t.Helper()
+	if s.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", s.Len(), len(want))
+	}
+
+	var got []int
+	s.Range(0, len(want)+1, func(i, v int) bool {
+		if i != len(got) {
+			t.Fatalf("Range index %d for element %d, want %d", i, len(got), len(got))
+		}
+		got = append(got, v)
+		return true
+	})
+	if !slices.Equal(got, want) {
+		t.Fatalf("Range = %v, want %v", got, want)
+	}
+
+	for offset := range want {
+		s.Range(offset, 2, func(i, v int) bool {
+			if v != want[i] {
+				t.Fatalf("Range(%d, 2) gave %d at index %d, want %d", offset, v, i, want[i])
+			}
+			return true
+		})
+	}
+
+	for rank, v := range want {
+		if got := s.CountBefore(func(x int) bool { return x < v }); got != rank {
+			t.Fatalf("CountBefore(< %d) = %d, want %d", v, got, rank)
+		}
+	}
+
+	// Each link's span must be the distance it covers at level 0.
+	index := make(map[*node[int]]int)
+	i := 0
+	for x := s.head.next[0]; x != nil; x = x.next[0] {
+		i++
+		index[x] = i
+	}
+	for x := s.head; x != nil; x = x.next[0] {
+		for level, next := range x.next {
+			if level >= s.level || next == nil {
+				continue
+			}
+			if span := index[next] - index[x]; x.span[level] != span {
+				t.Fatalf("span at index %d, level %d = %d, want %d", index[x], level, x.span[level], span)
+			}
+		}
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	tests := []struct {
+		name   string
+		insert []int
+		delete []int
+		want   []int
+	}{
+		{"empty", nil, nil, []int{}},
+		{"ascending", []int{1, 2, 3, 4, 5}, nil, []int{1, 2, 3, 4, 5}},
+		{"descending", []int{5, 4, 3, 2, 1}, nil, []int{1, 2, 3, 4, 5}},
+		{"delete first", []int{3, 1, 2}, []int{1}, []int{2, 3}},
+		{"delete last", []int{3, 1, 2}, []int{3}, []int{1, 2}},
+		{"delete middle", []int{3, 1, 2}, []int{2}, []int{1, 3}},
+		{"delete all", []int{3, 1, 2}, []int{2, 3, 1}, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSkipList(intLess)
+			for _, v := range tt.insert {
+				s.Insert(v)
+			}
+			for _, v := range tt.delete {
+				if !s.Delete(v) {
+					t.Fatalf("Delete(%d) = false, want true", v)
+				}
+			}
+			checkList(t, s, tt.want)
+		})
+	}
+}
+
+func TestSkipListDeleteMissing(t *testing.T) {
+	s := NewSkipList(intLess)
+	for _, v := range []int{10, 20, 30} {
+		s.Insert(v)
+	}
+	for _, v := range []int{5, 15, 35} {
+		if s.Delete(v) {
+			t.Errorf("Delete(%d) = true for a missing element", v)
+		}
+	}
+	checkList(t, s, []int{10, 20, 30})
+}
+
+// TestSkipListChurn grows the list far enough to use several levels,
+// checking it against a sorted slice as elements come and go.
+func TestSkipListChurn(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	s := NewSkipList(intLess)
+	var want []int
+	for step := 0; step < 2000; step++ {
+		if len(want) > 0 && rng.IntN(3) == 0 {
+			v := want[rng.IntN(len(want))]
+			if !s.Delete(v) {
+				t.Fatalf("Delete(%d) = false, want true", v)
+			}
+			want = slices.DeleteFunc(want, func(x int) bool { return x == v })
+		} else {
+			v := rng.IntN(1 << 20)
+			if slices.Contains(want, v) {
+				continue
+			}
+			s.Insert(v)
+			want = append(want, v)
+			slices.Sort(want)
+		}
+		if step%100 == 0 {
+			checkList(t, s, want)
+		}
+	}
+	checkList(t, s, want)
+}
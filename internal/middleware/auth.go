@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"connect4/internal/models"
+	"connect4/internal/services"
+	"connect4/internal/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const playerKey = "player"
+
+// RequireAuth rejects requests without a valid session token and makes the
+// signed-in player available to handlers through CurrentPlayer.
+func RequireAuth(auth *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		player, err := auth.Authenticate(BearerToken(c.Request))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "AUTH_ERROR", "Failed to check session")
+			c.Abort()
+			return
+		}
+		if player == nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "UNAUTHORIZED", "Sign in or play as a guest first")
+			c.Abort()
+			return
+		}
+		c.Set(playerKey, player)
+		c.Next()
+	}
+}
+
+// CurrentPlayer returns the player set by RequireAuth.
+func CurrentPlayer(c *gin.Context) *models.Player {
+	player, _ := c.Get(playerKey)
+	p, _ := player.(*models.Player)
+	return p
+}
+
+// BearerToken reads the session token from the Authorization header or,
+// since browsers cannot set headers on WebSocket requests, the token query
+// parameter.
+func BearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("token")
+}
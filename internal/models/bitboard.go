@@ -0,0 +1,149 @@
+package models
+
+const (
+	boardRows = 6
+	boardCols = 7
+
+	// colStride is the number of bits per column: one per row plus an empty
+	// sentinel bit on top, so shifts never carry a line from one column into
+	// the next.
+	colStride = boardRows + 1
+)
+
+var bottomMask = func() uint64 {
+	var m uint64
+	for col := 0; col < boardCols; col++ {
+		m |= 1 << (col * colStride)
+	}
+	return m
+}()
+
+// Bitboard is a compact form of Board: one bit mask per player plus the
+// height of each column. Bit col*7+h is the cell h rows above the bottom of
+// col, so Board row r maps to height 5-r. Bitboard is a small value type and
+// is cheap to copy.
+type Bitboard struct {
+	discs   [2]uint64
+	heights [boardCols]uint8
+	moves   uint8
+}
+
+func NewBitboard() Bitboard {
+	return Bitboard{}
+}
+
+// BitboardFromBoard converts a Board. Cells are read bottom-up per column,
+// so a disc floating above an empty cell is ignored.
+func BitboardFromBoard(b Board) Bitboard {
+	var bb Bitboard
+	for col := 0; col < boardCols; col++ {
+		for row := boardRows - 1; row >= 0; row-- {
+			p := b[row][col]
+			if p != 1 && p != 2 {
+				break
+			}
+			bb.DropDisc(col, p)
+		}
+	}
+	return bb
+}
+
+// Board returns the equivalent Board, which is what goes over the wire.
+func (bb *Bitboard) Board() Board {
+	var b Board
+	for col := 0; col < boardCols; col++ {
+		for h := 0; h < int(bb.heights[col]); h++ {
+			bit := uint64(1) << (col*colStride + h)
+			if bb.discs[0]&bit != 0 {
+				b[boardRows-1-h][col] = 1
+			} else {
+				b[boardRows-1-h][col] = 2
+			}
+		}
+	}
+	return b
+}
+
+func (bb *Bitboard) IsValidMove(column int) bool {
+	return column >= 0 && column < boardCols && bb.heights[column] < boardRows
+}
+
+// DropDisc plays a disc for playerNum (1 or 2) and returns the Board row it
+// landed on, or -1 if the column is full.
+func (bb *Bitboard) DropDisc(column int, playerNum int) int {
+	if !bb.IsValidMove(column) {
+		return -1
+	}
+	h := int(bb.heights[column])
+	bb.discs[playerNum-1] |= 1 << (column*colStride + h)
+	bb.heights[column]++
+	bb.moves++
+	return boardRows - 1 - h
+}
+
+// CheckWin reports whether playerNum has four in a row anywhere.
+func (bb *Bitboard) CheckWin(playerNum int) bool {
+	return hasFour(bb.discs[playerNum-1])
+}
+
+// IsWinningMove reports whether playing column would give playerNum four in
+// a row, without modifying the board.
+func (bb *Bitboard) IsWinningMove(column int, playerNum int) bool {
+	if !bb.IsValidMove(column) {
+		return false
+	}
+	bit := uint64(1) << (column*colStride + int(bb.heights[column]))
+	return hasFour(bb.discs[playerNum-1] | bit)
+}
+
+func (bb *Bitboard) IsFull() bool {
+	return int(bb.moves) == boardRows*boardCols
+}
+
+func (bb *Bitboard) MoveCount() int {
+	return int(bb.moves)
+}
+
+func (bb *Bitboard) Height(column int) int {
+	return int(bb.heights[column])
+}
+
+// Discs returns playerNum's disc mask in the layout described on Bitboard.
+func (bb *Bitboard) Discs(playerNum int) uint64 {
+	return bb.discs[playerNum-1]
+}
+
+// Key returns a value that uniquely identifies the position.
+func (bb *Bitboard) Key() uint64 {
+	return bb.discs[0] + (bb.discs[0] | bb.discs[1]) + bottomMask
+}
+
+// Mirror returns the position reflected left to right.
+func (bb *Bitboard) Mirror() Bitboard {
+	m := Bitboard{moves: bb.moves}
+	for col := 0; col < boardCols; col++ {
+		to := boardCols - 1 - col
+		m.heights[to] = bb.heights[col]
+		for p := range bb.discs {
+			column := bb.discs[p] >> (col * colStride) & (1<<boardRows - 1)
+			m.discs[p] |= column << (to * colStride)
+		}
+	}
+	return m
+}
+
+// CellMask returns the bit for the Board cell at row, col.
+func CellMask(row, col int) uint64 {
+	return 1 << (col*colStride + boardRows - 1 - row)
+}
+
+func hasFour(m uint64) bool {
+	// Vertical, horizontal and the two diagonals.
+	for _, shift := range [4]uint{1, colStride, colStride - 1, colStride + 1} {
+		pairs := m & (m >> shift)
+		if pairs&(pairs>>(2*shift)) != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GameResult string
+
+const (
+	GameResultWin     GameResult = "win"
+	GameResultLoss    GameResult = "loss"
+	GameResultDraw    GameResult = "draw"
+	GameResultOngoing GameResult = "ongoing"
+)
+
+// GameHistoryEntry is one of a player's games, from their side.
+type GameHistoryEntry struct {
+	GameID          uuid.UUID   `json:"game_id"`
+	Opponent        string      `json:"opponent"`
+	OpponentIsBot   bool        `json:"opponent_is_bot"`
+	Color           PlayerColor `json:"color"`
+	Result          GameResult  `json:"result"`
+	Status          GameStatus  `json:"status"`
+	TimeControl     string      `json:"time_control"`
+	TotalMoves      int         `json:"total_moves"`
+	DurationSeconds *int        `json:"duration_seconds"`
+	StartedAt       time.Time   `json:"started_at"`
+	CompletedAt     *time.Time  `json:"completed_at"`
+}
+
+// HeadToHead sums up the finished games between two players, from PlayerA's
+// side.
+type HeadToHead struct {
+	PlayerA                string             `json:"player_a"`
+	PlayerB                string             `json:"player_b"`
+	GamesPlayed            int                `json:"games_played"`
+	PlayerAWins            int                `json:"player_a_wins"`
+	PlayerBWins            int                `json:"player_b_wins"`
+	Draws                  int                `json:"draws"`
+	PlayerAForfeits        int                `json:"player_a_forfeits"`
+	PlayerBForfeits        int                `json:"player_b_forfeits"`
+	AverageMoves           float64            `json:"average_moves"`
+	AverageDurationSeconds float64            `json:"average_duration_seconds"`
+	RecentGames            []GameHistoryEntry `json:"recent_games"`
+}
+
+// GameHistoryFilter narrows a player's game history. Zero fields match
+// every game. History is newest first; After continues a page from the
+// cursor of the last game seen.
+type GameHistoryFilter struct {
+	Result   GameResult
+	Opponent string
+	VsBot    *bool
+	Status   GameStatus
+	From     *time.Time
+	To       *time.Time
+	After    *GameCursor
+	Limit    int
+}
+
+// GameCursor marks a place in a player's game history, which is ordered by
+// start time and then game ID.
+type GameCursor struct {
+	StartedAt time.Time
+	GameID    uuid.UUID
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// String encodes the cursor for use in a URL.
+func (c GameCursor) String() string {
+	raw := strconv.FormatInt(c.StartedAt.UnixMicro(), 10) + ":" + c.GameID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseGameCursor(s string) (*GameCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	n, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	gameID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	// Timestamps come back from the database in UTC; keep the cursor in the
+	// same zone so it compares equal to what it was made from.
+	return &GameCursor{StartedAt: time.UnixMicro(n).UTC(), GameID: gameID}, nil
+}
@@ -7,12 +7,16 @@ import (
 )
 
 type Player struct {
-	ID          int       `json:"id" db:"id"`
-	Username    string    `json:"username" db:"username"`
-	GamesPlayed int       `json:"games_played" db:"games_played"`
-	GamesWon    int       `json:"games_won" db:"games_won"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID               int       `json:"id" db:"id"`
+	Username         string    `json:"username" db:"username"`
+	GamesPlayed      int       `json:"games_played" db:"games_played"`
+	GamesWon         int       `json:"games_won" db:"games_won"`
+	Rating           float64   `json:"rating" db:"rating"`
+	RatingDeviation  float64   `json:"rating_deviation" db:"rating_deviation"`
+	RatingVolatility float64   `json:"rating_volatility" db:"rating_volatility"`
+	IsGuest          bool      `json:"is_guest" db:"is_guest"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type GameStatus string
@@ -22,6 +26,18 @@ const (
 	GameStatusCompleted GameStatus = "completed"
 	GameStatusForfeited GameStatus = "forfeited"
 	GameStatusDraw      GameStatus = "draw"
+	GameStatusTimeout   GameStatus = "timeout"
+	GameStatusResigned  GameStatus = "resigned"
+)
+
+// MoveAction is what a game_moves row records: a disc played, or the game
+// ended by a resignation or an agreed draw.
+type MoveAction string
+
+const (
+	MoveActionMove   MoveAction = "move"
+	MoveActionResign MoveAction = "resign"
+	MoveActionDraw   MoveAction = "draw"
 )
 
 type Game struct {
@@ -31,6 +47,7 @@ type Game struct {
 	Player2IsBot    bool       `json:"player2_is_bot" db:"player2_is_bot"`
 	WinnerID        *int       `json:"winner_id" db:"winner_id"`
 	Status          GameStatus `json:"status" db:"status"`
+	TimeControl     string     `json:"time_control" db:"time_control"`
 	DurationSeconds *int       `json:"duration_seconds" db:"duration_seconds"`
 	TotalMoves      int        `json:"total_moves" db:"total_moves"`
 	StartedAt       time.Time  `json:"started_at" db:"started_at"`
@@ -38,6 +55,39 @@ type Game struct {
 	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
 }
 
+// GameRecord is a stored game with its players' names and its moves, for
+// replaying it.
+type GameRecord struct {
+	Game
+	Player1 string     `json:"player1"`
+	Player2 string     `json:"player2"`
+	Winner  *string    `json:"winner"`
+	Moves   []GameMove `json:"moves"`
+}
+
+// GameMove is one row of game_moves. Column and Row are nil for a
+// resignation or an agreed draw.
+type GameMove struct {
+	MoveNumber int         `json:"move_number"`
+	PlayerID   int         `json:"player_id"`
+	Color      PlayerColor `json:"color"`
+	Action     MoveAction  `json:"action"`
+	Column     *int        `json:"column,omitempty"`
+	Row        *int        `json:"row,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// ReplayPosition is the board of a stored game after its first MoveNumber
+// moves.
+type ReplayPosition struct {
+	GameID     uuid.UUID   `json:"game_id"`
+	MoveNumber int         `json:"move_number"`
+	TotalMoves int         `json:"total_moves"`
+	Board      Board       `json:"board"`
+	NextTurn   PlayerColor `json:"next_turn"`
+	LastMove   *GameMove   `json:"last_move,omitempty"`
+}
+
 type PlayerColor string
 
 const (
@@ -46,11 +96,13 @@ const (
 )
 
 type PlayerInfo struct {
-	ID       int         `json:"id"`
-	Username string      `json:"username"`
-	Color    PlayerColor `json:"color"`
-	IsBot    bool        `json:"is_bot"`
-	SocketID string      `json:"socket_id,omitempty"`
+	ID         int         `json:"id"`
+	Username   string      `json:"username"`
+	Color      PlayerColor `json:"color"`
+	IsBot      bool        `json:"is_bot"`
+	Difficulty string      `json:"difficulty,omitempty"`
+	Engine     string      `json:"engine,omitempty"`
+	SocketID   string      `json:"socket_id,omitempty"`
 }
 
 type Board [6][7]int
@@ -60,20 +112,61 @@ type GameState struct {
 	Player1     PlayerInfo  `json:"player1"`
 	Player2     PlayerInfo  `json:"player2"`
 	Board       Board       `json:"board"`
+	Position    Bitboard    `json:"-"`
 	CurrentTurn PlayerColor `json:"current_turn"`
 	Status      GameStatus  `json:"status"`
 	Winner      *string     `json:"winner,omitempty"`
 	MoveCount   int         `json:"move_count"`
 	StartedAt   time.Time   `json:"started_at"`
 	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+
+	// Clocks for timed games. The side to move's time is charged from
+	// TurnStartedAt when they move.
+	TimeControl   TimeControl   `json:"time_control"`
+	RedTime       time.Duration `json:"-"`
+	YellowTime    time.Duration `json:"-"`
+	TurnStartedAt time.Time     `json:"-"`
+
+	// Player IDs with an open draw offer or rematch request, or 0.
+	DrawOfferedBy      int `json:"-"`
+	RematchRequestedBy int `json:"-"`
+	// RematchGameID is set once the rematch has started.
+	RematchGameID *uuid.UUID `json:"-"`
 }
 
 type WaitingPlayer struct {
-	Username  string    `json:"username"`
-	PlayerID  int       `json:"player_id"`
-	SocketID  string    `json:"socket_id"`
-	JoinedAt  time.Time `json:"joined_at"`
-	TimerDone bool      `json:"timer_done"`
+	Username      string      `json:"username"`
+	PlayerID      int         `json:"player_id"`
+	SocketID      string      `json:"socket_id"`
+	BotDifficulty string      `json:"bot_difficulty,omitempty"`
+	Rating        float64     `json:"rating"`
+	TimeControl   TimeControl `json:"time_control"`
+	JoinedAt      time.Time   `json:"joined_at"`
+	TimerDone     bool        `json:"timer_done"`
+}
+
+// Room is a private lobby that a host shares by its invite code. The game
+// starts when another player joins while the host is in the lobby.
+type Room struct {
+	Code         string    `json:"code"`
+	Host         string    `json:"host"`
+	HostID       int       `json:"-"`
+	HostSocketID string    `json:"-"`
+	HostInLobby  bool      `json:"host_in_lobby"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Challenge is an invitation from one online player to another to play a
+// game, pending until the opponent answers or it times out.
+type Challenge struct {
+	ID           uuid.UUID `json:"challenge_id"`
+	Challenger   string    `json:"challenger"`
+	ChallengerID int       `json:"-"`
+	SocketID     string    `json:"-"`
+	Opponent     string    `json:"opponent"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 type DisconnectedPlayer struct {
@@ -84,29 +177,121 @@ type DisconnectedPlayer struct {
 }
 
 type LeaderboardEntry struct {
-	ID          int       `json:"id" db:"id"`
-	Username    string    `json:"username" db:"username"`
-	GamesWon    int       `json:"games_won" db:"games_won"`
-	GamesPlayed int       `json:"games_played" db:"games_played"`
-	WinRate     float64   `json:"win_rate" db:"win_rate"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Rank            int       `json:"rank" db:"rank"`
+	ID              int       `json:"id" db:"id"`
+	Username        string    `json:"username" db:"username"`
+	GamesWon        int       `json:"games_won" db:"games_won"`
+	GamesPlayed     int       `json:"games_played" db:"games_played"`
+	WinRate         float64   `json:"win_rate" db:"win_rate"`
+	Rating          float64   `json:"rating" db:"rating"`
+	RatingDeviation float64   `json:"rating_deviation" db:"rating_deviation"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+type LeaderboardSort string
+
+const (
+	LeaderboardSortWins   LeaderboardSort = "wins"
+	LeaderboardSortRating LeaderboardSort = "rating"
+)
+
+// LeaderboardPeriod is the rolling window of finished games a leaderboard
+// counts.
+type LeaderboardPeriod string
+
+const (
+	LeaderboardPeriodDaily   LeaderboardPeriod = "daily"
+	LeaderboardPeriodWeekly  LeaderboardPeriod = "weekly"
+	LeaderboardPeriodMonthly LeaderboardPeriod = "monthly"
+	LeaderboardPeriodAllTime LeaderboardPeriod = "all-time"
+)
+
+// Since returns when the period began, counting back from now, or nil for
+// all time.
+func (p LeaderboardPeriod) Since(now time.Time) *time.Time {
+	var since time.Time
+	switch p {
+	case LeaderboardPeriodDaily:
+		since = now.AddDate(0, 0, -1)
+	case LeaderboardPeriodWeekly:
+		since = now.AddDate(0, 0, -7)
+	case LeaderboardPeriodMonthly:
+		since = now.AddDate(0, -1, 0)
+	default:
+		return nil
+	}
+	return &since
+}
+
+func (p LeaderboardPeriod) IsValid() bool {
+	switch p {
+	case LeaderboardPeriodDaily, LeaderboardPeriodWeekly, LeaderboardPeriodMonthly, LeaderboardPeriodAllTime:
+		return true
+	}
+	return false
+}
+
+// LeaderboardQuery picks which leaderboard to read and which page of it.
+type LeaderboardQuery struct {
+	Sort        LeaderboardSort
+	Period      LeaderboardPeriod
+	ExcludeBots bool
+	Limit       int
+	Offset      int
+}
+
+type RatingChange struct {
+	GameID           uuid.UUID `json:"game_id" db:"game_id"`
+	RatingBefore     float64   `json:"rating_before" db:"rating_before"`
+	RatingAfter      float64   `json:"rating_after" db:"rating_after"`
+	RatingDeviation  float64   `json:"rating_deviation" db:"rating_deviation"`
+	RatingVolatility float64   `json:"rating_volatility" db:"rating_volatility"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 }
 
 type WSMessageType string
 
 const (
-	WSJoinMatchmaking      WSMessageType = "join-matchmaking"
-	WSMakeMove             WSMessageType = "make-move"
-	WSReconnectGame        WSMessageType = "reconnect-game"
-	WSGameStarted          WSMessageType = "game-started"
-	WSMoveAccepted         WSMessageType = "move-accepted"
-	WSOpponentMoved        WSMessageType = "opponent-moved"
-	WSGameOver             WSMessageType = "game-over"
-	WSOpponentDisconnected WSMessageType = "opponent-disconnected"
-	WSOpponentReconnected  WSMessageType = "opponent-reconnected"
-	WSGameRestored         WSMessageType = "game-restored"
-	WSError                WSMessageType = "error"
-	WSMatchmakingStatus    WSMessageType = "matchmaking-status"
+	WSJoinMatchmaking       WSMessageType = "join-matchmaking"
+	WSMakeMove              WSMessageType = "make-move"
+	WSReconnectGame         WSMessageType = "reconnect-game"
+	WSGameStarted           WSMessageType = "game-started"
+	WSMoveAccepted          WSMessageType = "move-accepted"
+	WSOpponentMoved         WSMessageType = "opponent-moved"
+	WSGameOver              WSMessageType = "game-over"
+	WSOpponentDisconnected  WSMessageType = "opponent-disconnected"
+	WSOpponentReconnected   WSMessageType = "opponent-reconnected"
+	WSGameRestored          WSMessageType = "game-restored"
+	WSError                 WSMessageType = "error"
+	WSMatchmakingStatus     WSMessageType = "matchmaking-status"
+	WSCreateRoom            WSMessageType = "create-room"
+	WSJoinRoom              WSMessageType = "join-room"
+	WSRoomCreated           WSMessageType = "room-created"
+	WSRoomExpired           WSMessageType = "room-expired"
+	WSChallengePlayer       WSMessageType = "challenge-player"
+	WSAcceptChallenge       WSMessageType = "accept-challenge"
+	WSDeclineChallenge      WSMessageType = "decline-challenge"
+	WSChallengeSent         WSMessageType = "challenge-sent"
+	WSChallengeReceived     WSMessageType = "challenge-received"
+	WSChallengeDeclined     WSMessageType = "challenge-declined"
+	WSChallengeExpired      WSMessageType = "challenge-expired"
+	WSChallengeCancelled    WSMessageType = "challenge-cancelled"
+	WSTournamentGameStarted WSMessageType = "tournament-game-started"
+	WSMoveTimeoutWarning    WSMessageType = "move-timeout-warning"
+	WSResign                WSMessageType = "resign"
+	WSOfferDraw             WSMessageType = "offer-draw"
+	WSRespondDraw           WSMessageType = "respond-draw"
+	WSRequestRematch        WSMessageType = "request-rematch"
+	WSAcceptRematch         WSMessageType = "accept-rematch"
+	WSDrawOffered           WSMessageType = "draw-offered"
+	WSDrawDeclined          WSMessageType = "draw-declined"
+	WSRematchRequested      WSMessageType = "rematch-requested"
+	WSSpectateGame          WSMessageType = "spectate-game"
+	WSStopSpectating        WSMessageType = "stop-spectating"
+	WSSpectateStarted       WSMessageType = "spectate-started"
+	WSChatMessage           WSMessageType = "chat-message"
+	WSMuteOpponent          WSMessageType = "mute-opponent"
+	WSUnmuteOpponent        WSMessageType = "unmute-opponent"
 )
 
 type WSMessage struct {
@@ -115,7 +300,80 @@ type WSMessage struct {
 }
 
 type JoinMatchmakingPayload struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
+	BotDifficulty string `json:"bot_difficulty,omitempty"`
+	TimeControl   string `json:"time_control,omitempty"`
+}
+
+type JoinRoomPayload struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type ChallengePlayerPayload struct {
+	Opponent string `json:"opponent" binding:"required"`
+}
+
+type ChallengeResponsePayload struct {
+	ChallengeID uuid.UUID `json:"challenge_id" binding:"required"`
+}
+
+// GameActionPayload names the game for resign, offer-draw, request-rematch,
+// accept-rematch, spectate-game, stop-spectating, mute-opponent and
+// unmute-opponent.
+type GameActionPayload struct {
+	GameID uuid.UUID `json:"game_id" binding:"required"`
+}
+
+type SendChatPayload struct {
+	GameID  uuid.UUID `json:"game_id" binding:"required"`
+	Message string    `json:"message" binding:"required"`
+}
+
+// ChatMessage is a chat line in a game, as stored and as sent to the
+// players and spectators.
+type ChatMessage struct {
+	ID          int       `json:"id"`
+	GameID      uuid.UUID `json:"game_id"`
+	Username    string    `json:"username"`
+	IsSpectator bool      `json:"is_spectator"`
+	Message     string    `json:"message"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type RespondDrawPayload struct {
+	GameID uuid.UUID `json:"game_id" binding:"required"`
+	Accept bool      `json:"accept"`
+}
+
+// GameOfferPayload tells a player about their opponent's draw offer or
+// rematch request, or that their own draw offer was declined.
+type GameOfferPayload struct {
+	GameID uuid.UUID `json:"game_id"`
+	From   string    `json:"from"`
+}
+
+// SpectateStartedPayload gives a new spectator the game as it stands.
+// After it they get the same opponent-moved and game-over messages as the
+// players.
+type SpectateStartedPayload struct {
+	GameID      uuid.UUID   `json:"game_id"`
+	Player1     PlayerInfo  `json:"player1"`
+	Player2     PlayerInfo  `json:"player2"`
+	Board       Board       `json:"board"`
+	CurrentTurn PlayerColor `json:"current_turn"`
+	MoveCount   int         `json:"move_count"`
+	TimeControl string      `json:"time_control"`
+	Clocks      *Clocks     `json:"clocks,omitempty"`
+}
+
+// LiveGame summarises a game in progress for the list of games to watch.
+type LiveGame struct {
+	GameID      uuid.UUID   `json:"game_id"`
+	Player1     PlayerInfo  `json:"player1"`
+	Player2     PlayerInfo  `json:"player2"`
+	CurrentTurn PlayerColor `json:"current_turn"`
+	MoveCount   int         `json:"move_count"`
+	TimeControl string      `json:"time_control"`
+	StartedAt   time.Time   `json:"started_at"`
 }
 
 type MakeMovePayload struct {
@@ -124,11 +382,40 @@ type MakeMovePayload struct {
 }
 
 type GameStartedPayload struct {
-	GameID      uuid.UUID   `json:"game_id"`
-	Opponent    string      `json:"opponent"`
-	YourColor   PlayerColor `json:"your_color"`
-	CurrentTurn PlayerColor `json:"current_turn"`
-	IsBot       bool        `json:"is_bot"`
+	GameID        uuid.UUID   `json:"game_id"`
+	Opponent      string      `json:"opponent"`
+	YourColor     PlayerColor `json:"your_color"`
+	CurrentTurn   PlayerColor `json:"current_turn"`
+	IsBot         bool        `json:"is_bot"`
+	BotDifficulty string      `json:"bot_difficulty,omitempty"`
+	TimeControl   string      `json:"time_control,omitempty"`
+	Clocks        *Clocks     `json:"clocks,omitempty"`
+}
+
+type TournamentGameStartedPayload struct {
+	TournamentID int `json:"tournament_id"`
+	Round        int `json:"round"`
+	GameStartedPayload
+}
+
+type CreateTournamentPayload struct {
+	Name   string `json:"name" binding:"required,min=3,max=100"`
+	Format string `json:"format"`
+	Rounds int    `json:"rounds" binding:"min=0,max=50"`
+}
+
+// CredentialsPayload is the body of register and login requests.
+type CredentialsPayload struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Session is a signed-in player and the bearer token that identifies them
+// until ExpiresAt.
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Player    *Player   `json:"player"`
 }
 
 type MovePayload struct {
@@ -138,6 +425,7 @@ type MovePayload struct {
 	NextTurn   PlayerColor `json:"next_turn"`
 	Board      Board       `json:"board"`
 	MoveNumber int         `json:"move_number"`
+	Clocks     *Clocks     `json:"clocks,omitempty"`
 }
 
 type GameOverPayload struct {
@@ -147,6 +435,11 @@ type GameOverPayload struct {
 	Duration int     `json:"duration_seconds"`
 }
 
+type MoveTimeoutWarningPayload struct {
+	GameID           uuid.UUID `json:"game_id"`
+	SecondsRemaining int       `json:"seconds_remaining"`
+}
+
 type ErrorPayload struct {
 	Message string `json:"message"`
 	Code    string `json:"code,omitempty"`
@@ -222,4 +515,4 @@ func (b *Board) Copy() Board {
 		}
 	}
 	return newBoard
-}
\ No newline at end of file
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxBase      = 180 * time.Minute
+	maxIncrement = time.Minute
+	minPerMove   = 5 * time.Second
+	maxPerMove   = 10 * time.Minute
+)
+
+// TimeControl limits how long players may think. With Base set each player
+// has a clock that starts at Base and gains Increment after each of their
+// moves; with PerMove set every move must be made within PerMove. The zero
+// value is an untimed game.
+//
+// As text a time control is written "5+3" for five minutes plus three
+// seconds a move, "move:30" for thirty seconds a move, or "" for untimed.
+type TimeControl struct {
+	Base      time.Duration
+	Increment time.Duration
+	PerMove   time.Duration
+}
+
+// Clocks is the time each player has left, in milliseconds.
+type Clocks struct {
+	RedMs    int64 `json:"red_ms"`
+	YellowMs int64 `json:"yellow_ms"`
+}
+
+func ParseTimeControl(s string) (TimeControl, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return TimeControl{}, nil
+	}
+	if seconds, ok := strings.CutPrefix(s, "move:"); ok {
+		n, err := strconv.Atoi(seconds)
+		perMove := time.Duration(n) * time.Second
+		if err != nil || perMove < minPerMove || perMove > maxPerMove {
+			return TimeControl{}, fmt.Errorf("invalid time control %q: seconds per move must be %d-%d", s, int(minPerMove.Seconds()), int(maxPerMove.Seconds()))
+		}
+		return TimeControl{PerMove: perMove}, nil
+	}
+	minutes, seconds, ok := strings.Cut(s, "+")
+	if !ok {
+		return TimeControl{}, fmt.Errorf("invalid time control %q: want minutes+increment or move:seconds", s)
+	}
+	m, err1 := strconv.Atoi(minutes)
+	inc, err2 := strconv.Atoi(seconds)
+	base := time.Duration(m) * time.Minute
+	increment := time.Duration(inc) * time.Second
+	if err1 != nil || err2 != nil || base <= 0 || base > maxBase || increment < 0 || increment > maxIncrement {
+		return TimeControl{}, fmt.Errorf("invalid time control %q: want 1-%d minutes and 0-%d seconds increment", s, int(maxBase.Minutes()), int(maxIncrement.Seconds()))
+	}
+	return TimeControl{Base: base, Increment: increment}, nil
+}
+
+func (tc TimeControl) IsTimed() bool {
+	return tc.Base > 0 || tc.PerMove > 0
+}
+
+func (tc TimeControl) String() string {
+	switch {
+	case tc.PerMove > 0:
+		return fmt.Sprintf("move:%d", int(tc.PerMove.Seconds()))
+	case tc.Base > 0:
+		return fmt.Sprintf("%d+%d", int(tc.Base.Minutes()), int(tc.Increment.Seconds()))
+	}
+	return ""
+}
+
+func (tc TimeControl) MarshalText() ([]byte, error) {
+	return []byte(tc.String()), nil
+}
+
+func (tc *TimeControl) UnmarshalText(text []byte) error {
+	parsed, err := ParseTimeControl(string(text))
+	if err != nil {
+		return err
+	}
+	*tc = parsed
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package rating implements the Glicko-2 rating system
+// (http://www.glicko.net/glicko/glicko2.pdf). Each game is treated as its own
+// rating period.
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	fixedDeviation = 50.0
+
+	// tau limits how fast volatility can change; the paper suggests 0.3-1.2.
+	tau = 0.5
+	// scale converts between the Glicko and Glicko-2 scales.
+	scale = 173.7178
+	// epsilon is the convergence tolerance for the volatility iteration.
+	epsilon = 0.000001
+)
+
+// Rating is a player's strength estimate: Value is the rating, Deviation
+// how uncertain it is and Volatility how erratic the player's results are.
+type Rating struct {
+	Value      float64 `json:"rating"`
+	Deviation  float64 `json:"rating_deviation"`
+	Volatility float64 `json:"rating_volatility"`
+}
+
+func Default() Rating {
+	return Rating{Value: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Fixed returns the rating of an opponent whose strength is known and does
+// not change, such as a bot.
+func Fixed(value float64) Rating {
+	return Rating{Value: value, Deviation: fixedDeviation, Volatility: DefaultVolatility}
+}
+
+// Result is one game from the player's point of view. Score is 1 for a win,
+// 0.5 for a draw and 0 for a loss.
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+const (
+	Win  = 1.0
+	Draw = 0.5
+	Loss = 0.0
+)
+
+// Update returns the player's rating after the results of one rating
+// period. With no results only the deviation grows.
+func Update(player Rating, results []Result) Rating {
+	mu := (player.Value - DefaultRating) / scale
+	phi := player.Deviation / scale
+	sigma := player.Volatility
+
+	if len(results) == 0 {
+		phiStar := math.Sqrt(phi*phi + sigma*sigma)
+		return Rating{Value: player.Value, Deviation: math.Min(phiStar*scale, DefaultDeviation), Volatility: sigma}
+	}
+
+	var invV, sum float64
+	for _, r := range results {
+		muJ := (r.Opponent.Value - DefaultRating) / scale
+		phiJ := r.Opponent.Deviation / scale
+		gJ := g(phiJ)
+		e := expected(mu, muJ, gJ)
+		invV += gJ * gJ * e * (1 - e)
+		sum += gJ * (r.Score - e)
+	}
+	v := 1 / invV
+	delta := v * sum
+
+	sigmaNew := newVolatility(sigma, phi, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigmaNew*sigmaNew)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Rating{
+		Value:      muNew*scale + DefaultRating,
+		Deviation:  math.Min(phiNew*scale, DefaultDeviation),
+		Volatility: sigmaNew,
+	}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muJ, gJ float64) float64 {
+	return 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+}
+
+// newVolatility solves step 5 of the paper with the Illinois algorithm.
+func newVolatility(sigma, phi, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
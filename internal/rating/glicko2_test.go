@@ -0,0 +1,61 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		player  Rating
+		results []Result
+		want    Rating
+	}{
+		{
+			// The worked example in section 3 of the Glicko-2 paper.
+			name:   "paper example",
+			player: Rating{Value: 1500, Deviation: 200, Volatility: 0.06},
+			results: []Result{
+				{Opponent: Rating{Value: 1400, Deviation: 30}, Score: Win},
+				{Opponent: Rating{Value: 1550, Deviation: 100}, Score: Loss},
+				{Opponent: Rating{Value: 1700, Deviation: 300}, Score: Loss},
+			},
+			want: Rating{Value: 1464.06, Deviation: 151.52, Volatility: 0.05999},
+		},
+		{
+			name:   "no games only widens the deviation",
+			player: Rating{Value: 1500, Deviation: 200, Volatility: 0.06},
+			want:   Rating{Value: 1500, Deviation: 200.27, Volatility: 0.06},
+		},
+		{
+			name:   "no games never passes the default deviation",
+			player: Default(),
+			want:   Default(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Update(tt.player, tt.results)
+			if math.Abs(got.Value-tt.want.Value) > 0.01 ||
+				math.Abs(got.Deviation-tt.want.Deviation) > 0.01 ||
+				math.Abs(got.Volatility-tt.want.Volatility) > 0.00001 {
+				t.Errorf("Update = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateWinRaisesAndLossLowers(t *testing.T) {
+	player := Default()
+	opponent := Fixed(DefaultRating)
+	if won := Update(player, []Result{{Opponent: opponent, Score: Win}}); won.Value <= player.Value {
+		t.Errorf("rating after a win = %.2f, want above %.2f", won.Value, player.Value)
+	}
+	if lost := Update(player, []Result{{Opponent: opponent, Score: Loss}}); lost.Value >= player.Value {
+		t.Errorf("rating after a loss = %.2f, want below %.2f", lost.Value, player.Value)
+	}
+	if drew := Update(player, []Result{{Opponent: opponent, Score: Draw}}); math.Abs(drew.Value-player.Value) > 0.01 {
+		t.Errorf("rating after a draw with an equal = %.2f, want %.2f", drew.Value, player.Value)
+	}
+}
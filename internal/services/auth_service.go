@@ -0,0 +1,165 @@
+package services
+
+import (
+	"connect4/internal/config"
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/pkg/logger"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidUsername    = errors.New("username must be 3-50 letters, digits, '_' or '-' and may not start with guest- or bot_")
+	ErrInvalidPassword    = errors.New("password must be 8-72 characters")
+	ErrUsernameTaken      = errors.New("username is taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordLength = 72
+	guestPrefix       = "Guest-"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,50}$`)
+
+type AuthService struct {
+	db         *database.Database
+	sessionTTL time.Duration
+	// dummyHash is checked against when a login names an unknown player, so
+	// that the response takes as long as for a wrong password.
+	dummyHash []byte
+}
+
+func NewAuthService(db *database.Database, cfg *config.Config) *AuthService {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return &AuthService{
+		db:         db,
+		sessionTTL: time.Duration(cfg.Server.SessionTTL) * time.Hour,
+		dummyHash:  dummyHash,
+	}
+}
+
+// Register creates an account and signs it in.
+func (as *AuthService) Register(username, password string) (*models.Session, error) {
+	if !validUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	player, err := as.db.CreateAccount(username, string(hash), false)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return nil, ErrUsernameTaken
+	}
+	logger.Log.Info("Account registered", zap.String("username", username))
+	return as.startSession(player)
+}
+
+func (as *AuthService) Login(username, password string) (*models.Session, error) {
+	playerID, hash, err := as.db.GetPasswordHash(username)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(as.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	player, err := as.db.GetPlayerByID(playerID)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return as.startSession(player)
+}
+
+// Guest signs in a new guest player named Guest-xxxxxxxx. Guests have no
+// password, so the identity lasts only as long as the session.
+func (as *AuthService) Guest() (*models.Session, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		player, err := as.db.CreateAccount(guestPrefix+hex.EncodeToString(suffix), "", true)
+		if err != nil {
+			return nil, err
+		}
+		if player != nil {
+			return as.startSession(player)
+		}
+	}
+	return nil, errors.New("failed to pick a guest name")
+}
+
+// Authenticate returns the player signed in with token, or nil if the token
+// is unknown or has expired.
+func (as *AuthService) Authenticate(token string) (*models.Player, error) {
+	if token == "" {
+		return nil, nil
+	}
+	return as.db.GetSessionPlayer(hashToken(token))
+}
+
+func (as *AuthService) Logout(token string) error {
+	return as.db.DeleteSession(hashToken(token))
+}
+
+// startSession issues a new token for the player. Only its hash is stored,
+// so a leaked sessions table does not let anyone sign in.
+func (as *AuthService) startSession(player *models.Player) (*models.Session, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := as.db.DeleteExpiredSessions(); err != nil {
+		logger.Log.Warn("Failed to delete expired sessions", zap.Error(err))
+	}
+	expiresAt, err := as.db.CreateSession(hashToken(token), player.ID, as.sessionTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Session{Token: token, ExpiresAt: expiresAt, Player: player}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// validUsername reports whether a name may be registered. Names starting
+// with guest- or bot_ are kept for guests and bots.
+func validUsername(username string) bool {
+	if !usernamePattern.MatchString(username) {
+		return false
+	}
+	lower := strings.ToLower(username)
+	return !strings.HasPrefix(lower, strings.ToLower(guestPrefix)) && !strings.HasPrefix(lower, "bot_") && lower != "bot"
+}
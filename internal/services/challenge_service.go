@@ -0,0 +1,206 @@
+package services
+
+import (
+	"connect4/internal/config"
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/pkg/logger"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ChallengeService struct {
+	db               *database.Database
+	config           *config.Config
+	gameService      *GameService
+	matchmaking      *MatchmakingService
+	rooms            *RoomService
+	challenges       map[uuid.UUID]*models.Challenge
+	challengesMutex  sync.Mutex
+	onStartCallback  func(challenger, opponent *models.WaitingPlayer, gameState *models.GameState)
+	onExpireCallback func(challenge *models.Challenge)
+}
+
+func NewChallengeService(db *database.Database, cfg *config.Config, gameService *GameService, matchmaking *MatchmakingService, rooms *RoomService) *ChallengeService {
+	return &ChallengeService{
+		db:          db,
+		config:      cfg,
+		gameService: gameService,
+		matchmaking: matchmaking,
+		rooms:       rooms,
+		challenges:  make(map[uuid.UUID]*models.Challenge),
+	}
+}
+
+func (cs *ChallengeService) SetStartCallback(callback func(challenger, opponent *models.WaitingPlayer, gameState *models.GameState)) {
+	cs.onStartCallback = callback
+}
+
+func (cs *ChallengeService) SetExpireCallback(callback func(challenge *models.Challenge)) {
+	cs.onExpireCallback = callback
+}
+
+// Challenge records a challenge from challenger, connected on socketID, to
+// opponent. Checking that the opponent is online is up to the caller.
+func (cs *ChallengeService) Challenge(challenger, socketID, opponent string) (*models.Challenge, error) {
+	if challenger == opponent {
+		return nil, errors.New("cannot challenge yourself")
+	}
+
+	player, err := cs.db.GetPlayerByUsername(challenger)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		player, err = cs.db.CreatePlayer(challenger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cs.challengesMutex.Lock()
+	defer cs.challengesMutex.Unlock()
+
+	for _, c := range cs.challenges {
+		if c.Challenger == challenger && c.Opponent == opponent {
+			return nil, errors.New("challenge already pending")
+		}
+	}
+
+	now := time.Now()
+	challenge := &models.Challenge{
+		ID:           uuid.New(),
+		Challenger:   challenger,
+		ChallengerID: player.ID,
+		SocketID:     socketID,
+		Opponent:     opponent,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Duration(cs.config.Game.ChallengeTimeout) * time.Second),
+	}
+	cs.challenges[challenge.ID] = challenge
+	go cs.startExpiryTimer(challenge.ID)
+
+	logger.Log.Info("Challenge sent", zap.String("challenger", challenger), zap.String("opponent", opponent))
+	return challenge, nil
+}
+
+// Accept starts the game for a challenge addressed to username. The
+// challenger plays red. Both players leave the matchmaking queue and any
+// room lobby they are waiting in, so neither is matched twice. inGame
+// reports whether a player is already playing; a challenger who is has the
+// challenge dropped.
+func (cs *ChallengeService) Accept(challengeID uuid.UUID, username, socketID string, inGame func(username string) bool) error {
+	challenge, err := cs.take(challengeID, username)
+	if err != nil {
+		return err
+	}
+	if inGame(challenge.Challenger) {
+		return errors.New("challenger is already in a game")
+	}
+
+	player, err := cs.db.GetPlayerByUsername(username)
+	if err != nil {
+		return err
+	}
+	if player == nil {
+		player, err = cs.db.CreatePlayer(username)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{challenge.Challenger, username} {
+		cs.matchmaking.LeaveQueue(name)
+		cs.rooms.LeaveLobby(name)
+	}
+
+	challengerInfo := models.PlayerInfo{
+		ID:       challenge.ChallengerID,
+		Username: challenge.Challenger,
+		Color:    models.ColorRed,
+		IsBot:    false,
+		SocketID: challenge.SocketID,
+	}
+	opponentInfo := models.PlayerInfo{
+		ID:       player.ID,
+		Username: username,
+		Color:    models.ColorYellow,
+		IsBot:    false,
+		SocketID: socketID,
+	}
+	gameState, err := cs.gameService.CreateGame(challengerInfo, opponentInfo)
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Info("Challenge accepted", zap.String("challenger", challenge.Challenger), zap.String("opponent", username))
+	if cs.onStartCallback != nil {
+		challenger := &models.WaitingPlayer{Username: challenge.Challenger, PlayerID: challenge.ChallengerID, SocketID: challenge.SocketID, JoinedAt: challenge.CreatedAt}
+		opponent := &models.WaitingPlayer{Username: username, PlayerID: player.ID, SocketID: socketID, Rating: player.Rating, JoinedAt: time.Now()}
+		cs.onStartCallback(challenger, opponent, gameState)
+	}
+	return nil
+}
+
+// Decline removes a challenge addressed to username and returns it so the
+// challenger can be told.
+func (cs *ChallengeService) Decline(challengeID uuid.UUID, username string) (*models.Challenge, error) {
+	challenge, err := cs.take(challengeID, username)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Challenge declined", zap.String("challenger", challenge.Challenger), zap.String("opponent", username))
+	return challenge, nil
+}
+
+// CancelFor drops every pending challenge sent by or to username, such as
+// when they disconnect or start another game, and returns them.
+func (cs *ChallengeService) CancelFor(username string) []*models.Challenge {
+	cs.challengesMutex.Lock()
+	defer cs.challengesMutex.Unlock()
+
+	var cancelled []*models.Challenge
+	for id, c := range cs.challenges {
+		if c.Challenger == username || c.Opponent == username {
+			delete(cs.challenges, id)
+			cancelled = append(cancelled, c)
+		}
+	}
+	return cancelled
+}
+
+// take removes and returns the challenge if it is addressed to username.
+func (cs *ChallengeService) take(challengeID uuid.UUID, username string) (*models.Challenge, error) {
+	cs.challengesMutex.Lock()
+	defer cs.challengesMutex.Unlock()
+
+	challenge, exists := cs.challenges[challengeID]
+	if !exists || challenge.Opponent != username {
+		return nil, errors.New("challenge not found")
+	}
+	delete(cs.challenges, challengeID)
+	return challenge, nil
+}
+
+func (cs *ChallengeService) startExpiryTimer(challengeID uuid.UUID) {
+	timeout := time.Duration(cs.config.Game.ChallengeTimeout) * time.Second
+	time.Sleep(timeout)
+
+	cs.challengesMutex.Lock()
+	challenge, exists := cs.challenges[challengeID]
+	if exists {
+		delete(cs.challenges, challengeID)
+	}
+	cs.challengesMutex.Unlock()
+
+	if exists {
+		logger.Log.Info("Challenge expired", zap.String("challenger", challenge.Challenger), zap.String("opponent", challenge.Opponent))
+		if cs.onExpireCallback != nil {
+			cs.onExpireCallback(challenge)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// WordFilter moderates chat messages before they are stored or sent. It
+// returns the message to send, which may be rewritten, or an error to
+// reject the message.
+type WordFilter interface {
+	Filter(message string) (string, error)
+}
+
+// BlocklistFilter masks whole-word, case-insensitive matches of its words
+// with asterisks.
+type BlocklistFilter struct {
+	pattern *regexp.Regexp
+}
+
+func NewBlocklistFilter(words []string) *BlocklistFilter {
+	if len(words) == 0 {
+		return &BlocklistFilter{}
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	return &BlocklistFilter{pattern: regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+func (f *BlocklistFilter) Filter(message string) (string, error) {
+	if f.pattern == nil {
+		return message, nil
+	}
+	return f.pattern.ReplaceAllStringFunc(message, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	}), nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"connect4/internal/config"
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/pkg/logger"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ChatService struct {
+	db          *database.Database
+	config      *config.Config
+	gameService *GameService
+	filter      WordFilter
+	// sent holds when each user sent the messages still inside the rate
+	// window, oldest first.
+	sent map[string][]time.Time
+	// muted holds, per game, the players who have muted their opponent.
+	muted     map[uuid.UUID]map[string]bool
+	chatMutex sync.Mutex
+}
+
+func NewChatService(db *database.Database, cfg *config.Config, gameService *GameService) *ChatService {
+	cs := &ChatService{
+		db:          db,
+		config:      cfg,
+		gameService: gameService,
+		filter:      NewBlocklistFilter(cfg.Game.ChatBlockedWords),
+		sent:        make(map[string][]time.Time),
+		muted:       make(map[uuid.UUID]map[string]bool),
+	}
+	gameService.AddGameEndCallback(cs.handleGameEnd)
+	return cs
+}
+
+// SetFilter replaces the filter applied to every message.
+func (cs *ChatService) SetFilter(filter WordFilter) {
+	cs.filter = filter
+}
+
+// Send checks, filters and stores a message from username to a game in
+// progress. Whether the sender may talk in the game is up to the caller.
+func (cs *ChatService) Send(gameID uuid.UUID, username string, isSpectator bool, text string) (*models.ChatMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("message is empty")
+	}
+	if utf8.RuneCountInString(text) > cs.config.Game.ChatMaxLength {
+		return nil, errors.New("message is too long")
+	}
+	game, err := cs.gameService.GetGameSnapshot(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if game.Status != models.GameStatusActive {
+		return nil, errors.New("game is not active")
+	}
+	if !cs.allow(username, time.Now()) {
+		return nil, errors.New("you are sending messages too quickly")
+	}
+
+	text, err = cs.filter.Filter(text)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &models.ChatMessage{
+		GameID:      gameID,
+		Username:    username,
+		IsSpectator: isSpectator,
+		Message:     text,
+		CreatedAt:   time.Now(),
+	}
+	if err := cs.db.SaveChatMessage(msg); err != nil {
+		logger.Log.Error("Failed to save chat message", zap.String("game_id", gameID.String()), zap.Error(err))
+	}
+	return msg, nil
+}
+
+// allow records a message from username at now, unless they have already
+// sent as many as the rate limit allows within the window.
+func (cs *ChatService) allow(username string, now time.Time) bool {
+	cs.chatMutex.Lock()
+	defer cs.chatMutex.Unlock()
+
+	cutoff := now.Add(-time.Duration(cs.config.Game.ChatRateWindow) * time.Second)
+	sent := cs.sent[username]
+	for len(sent) > 0 && !sent[0].After(cutoff) {
+		sent = sent[1:]
+	}
+	if len(sent) >= cs.config.Game.ChatRateLimit {
+		cs.sent[username] = sent
+		return false
+	}
+	cs.sent[username] = append(sent, now)
+	return true
+}
+
+// SetMuted mutes or unmutes username's opponent in a game for username
+// only.
+func (cs *ChatService) SetMuted(gameID uuid.UUID, username string, muted bool) {
+	cs.chatMutex.Lock()
+	defer cs.chatMutex.Unlock()
+
+	if !muted {
+		delete(cs.muted[gameID], username)
+		if len(cs.muted[gameID]) == 0 {
+			delete(cs.muted, gameID)
+		}
+		return
+	}
+	if cs.muted[gameID] == nil {
+		cs.muted[gameID] = make(map[string]bool)
+	}
+	cs.muted[gameID][username] = true
+}
+
+// HasMutedOpponent reports whether username has muted their opponent in a
+// game.
+func (cs *ChatService) HasMutedOpponent(gameID uuid.UUID, username string) bool {
+	cs.chatMutex.Lock()
+	defer cs.chatMutex.Unlock()
+	return cs.muted[gameID][username]
+}
+
+// handleGameEnd forgets the game's mutes and any rate-limit history that
+// has run out.
+func (cs *ChatService) handleGameEnd(game models.GameState) {
+	cs.chatMutex.Lock()
+	defer cs.chatMutex.Unlock()
+
+	delete(cs.muted, game.GameID)
+	cutoff := time.Now().Add(-time.Duration(cs.config.Game.ChatRateWindow) * time.Second)
+	for username, sent := range cs.sent {
+		if len(sent) == 0 || !sent[len(sent)-1].After(cutoff) {
+			delete(cs.sent, username)
+		}
+	}
+}
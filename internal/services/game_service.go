@@ -2,11 +2,16 @@ package services
 
 import (
 	"connect4/internal/bot"
+	"connect4/internal/config"
 	"connect4/internal/database"
 	"connect4/internal/models"
+	"connect4/internal/rating"
+	"connect4/internal/solver"
 	"connect4/pkg/logger"
+	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,24 +23,146 @@ type GameService struct {
 	db          *database.Database
 	activeGames map[uuid.UUID]*models.GameState
 	gamesMutex  sync.RWMutex
-	bot         *bot.Bot
+	engines     *bot.Registry
+	moveTimeout time.Duration
+	// ratingsMutex keeps rating updates in sequence, since each reads a
+	// player's rating and writes back a new one.
+	ratingsMutex sync.Mutex
+
+	clock Clock
+	// turnTimers fire when the side to move runs out of time in a timed
+	// game, or is idle for too long in an untimed one. Guarded by
+	// gamesMutex.
+	turnTimers  map[uuid.UUID][]Timer
+	idleTimeout time.Duration
+	idleWarning time.Duration
+
+	onGameEndCallbacks    []func(game models.GameState)
+	onTimeoutCallback     func(game *models.GameState, gameOver *models.GameOverPayload)
+	onIdleWarningCallback func(game *models.GameState, player models.PlayerInfo, remaining time.Duration)
+	onIdleForfeitCallback func(game *models.GameState, gameOver *models.GameOverPayload)
 }
 
-func NewGameService(db *database.Database) *GameService {
+func NewGameService(db *database.Database, cfg *config.Config, s *solver.Solver, book *bot.Book) *GameService {
+	budget := time.Duration(cfg.Game.BotMoveBudgetMs) * time.Millisecond
+	engines := bot.NewRegistry()
+	for _, d := range bot.Difficulties() {
+		b := bot.New(d, budget, book)
+		b.SetSolver(s)
+		_ = engines.Register(b)
+	}
+	_ = engines.Register(bot.RandomEngine{})
+	_ = engines.Register(bot.SolverEngine{Solver: s})
+
 	return &GameService{
 		db:          db,
 		activeGames: make(map[uuid.UUID]*models.GameState),
-		bot:         bot.New(),
+		engines:     engines,
+		// The bots keep to their own budget; the timeout only cuts off
+		// engines that overrun it.
+		moveTimeout: 2 * budget,
+		clock:       realClock{},
+		turnTimers:  make(map[uuid.UUID][]Timer),
+		idleTimeout: time.Duration(cfg.Game.MoveIdleTimeout) * time.Second,
+		idleWarning: time.Duration(cfg.Game.MoveIdleWarning) * time.Second,
+	}
+}
+
+// SetClock replaces the clock behind the turn timers. It must be called
+// before any games start.
+func (gs *GameService) SetClock(clock Clock) {
+	gs.clock = clock
+}
+
+// RegisterEngine makes an engine available to bot games by name.
+func (gs *GameService) RegisterEngine(e bot.Engine) error {
+	return gs.engines.Register(e)
+}
+
+func (gs *GameService) EngineNames() []string {
+	return gs.engines.Names()
+}
+
+// AddGameEndCallback registers a function to run whenever a game finishes,
+// including by forfeit. Callbacks run on their own goroutine with a copy of
+// the final state, so they may call back into GameService. Register them
+// before any games start.
+func (gs *GameService) AddGameEndCallback(callback func(game models.GameState)) {
+	gs.onGameEndCallbacks = append(gs.onGameEndCallbacks, callback)
+}
+
+// SetTimeoutCallback registers the function told when a player loses on
+// time, so both sides can be sent the game over.
+func (gs *GameService) SetTimeoutCallback(callback func(game *models.GameState, gameOver *models.GameOverPayload)) {
+	gs.onTimeoutCallback = callback
+}
+
+// SetIdleWarningCallback registers the function told when a player in an
+// untimed game has been idle long enough to be warned, with the time they
+// have left to move.
+func (gs *GameService) SetIdleWarningCallback(callback func(game *models.GameState, player models.PlayerInfo, remaining time.Duration)) {
+	gs.onIdleWarningCallback = callback
+}
+
+// SetIdleForfeitCallback registers the function told when a player forfeits
+// an untimed game by not moving.
+func (gs *GameService) SetIdleForfeitCallback(callback func(game *models.GameState, gameOver *models.GameOverPayload)) {
+	gs.onIdleForfeitCallback = callback
+}
+
+// notifyGameEnd updates the players' ratings and then runs the game-end
+// callbacks, all on a copy of the game away from gamesMutex, so the
+// database work never holds up other games. The ratings come first so that
+// callbacks such as the leaderboard's see them.
+func (gs *GameService) notifyGameEnd(game *models.GameState, winnerID *int) {
+	ended := *game
+	var winner *int
+	if winnerID != nil {
+		id := *winnerID
+		winner = &id
+	}
+	go func() {
+		gs.updateRatings(&ended, winner)
+		for _, callback := range gs.onGameEndCallbacks {
+			go callback(ended)
+		}
+	}()
+}
+
+// engineFor picks the engine named in the bot's PlayerInfo, falling back to
+// the minimax bot for its difficulty.
+func (gs *GameService) engineFor(player models.PlayerInfo) bot.Engine {
+	if player.Engine != "" {
+		if e, ok := gs.engines.Get(player.Engine); ok {
+			return e
+		}
+		logger.Log.Warn("Unknown bot engine, using minimax", zap.String("engine", player.Engine))
+	}
+	return gs.minimaxFor(player)
+}
+
+// minimaxFor returns the built-in minimax bot for the bot's difficulty.
+func (gs *GameService) minimaxFor(player models.PlayerInfo) bot.Engine {
+	if e, ok := gs.engines.Get(bot.MinimaxEngineName(bot.Difficulty(player.Difficulty))); ok {
+		return e
 	}
+	e, _ := gs.engines.Get(bot.MinimaxEngineName(bot.DefaultDifficulty))
+	return e
 }
 
 func (gs *GameService) CreateGame(player1 models.PlayerInfo, player2 models.PlayerInfo) (*models.GameState, error) {
+	return gs.CreateTimedGame(player1, player2, models.TimeControl{})
+}
+
+// CreateTimedGame starts a game played under the given time control. Red's
+// clock starts straight away.
+func (gs *GameService) CreateTimedGame(player1 models.PlayerInfo, player2 models.PlayerInfo, timeControl models.TimeControl) (*models.GameState, error) {
 	var player2ID *int
 	if !player2.IsBot {
 		player2ID = &player2.ID
 	}
 
-	dbGameID, err := gs.db.CreateGame(player1.ID, player2ID, player2.IsBot)
+	dbGameID, err := gs.db.CreateGame(player1.ID, player2ID, player2.IsBot, timeControl.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create game in database: %w", err)
 	}
@@ -45,14 +172,19 @@ func (gs *GameService) CreateGame(player1 models.PlayerInfo, player2 models.Play
 		Player1:     player1,
 		Player2:     player2,
 		Board:       models.NewBoard(),
+		Position:    models.NewBitboard(),
 		CurrentTurn: models.ColorRed,
 		Status:      models.GameStatusActive,
 		MoveCount:   0,
-		StartedAt:   time.Now(),
+		StartedAt:   gs.clock.Now(),
+		TimeControl: timeControl,
+		RedTime:     timeControl.Base,
+		YellowTime:  timeControl.Base,
 	}
 
 	gs.gamesMutex.Lock()
 	gs.activeGames[dbGameID] = gameState
+	gs.startTurn(gameState, gameState.StartedAt)
 	gs.gamesMutex.Unlock()
 
 	logger.Log.Info("Game created", zap.String("game_id", dbGameID.String()), zap.String("player1", player1.Username), zap.String("player2", player2.Username), zap.Bool("is_bot", player2.IsBot))
@@ -69,6 +201,42 @@ func (gs *GameService) GetGame(gameID uuid.UUID) (*models.GameState, error) {
 	return game, nil
 }
 
+// GetGameSnapshot returns a copy of the game that later moves will not
+// change.
+func (gs *GameService) GetGameSnapshot(gameID uuid.UUID) (models.GameState, error) {
+	gs.gamesMutex.RLock()
+	defer gs.gamesMutex.RUnlock()
+	game, exists := gs.activeGames[gameID]
+	if !exists {
+		return models.GameState{}, errors.New("game not found")
+	}
+	return *game, nil
+}
+
+// ListLiveGames returns the games in progress, newest first.
+func (gs *GameService) ListLiveGames() []models.LiveGame {
+	gs.gamesMutex.RLock()
+	defer gs.gamesMutex.RUnlock()
+
+	live := make([]models.LiveGame, 0)
+	for _, game := range gs.activeGames {
+		if game.Status != models.GameStatusActive {
+			continue
+		}
+		live = append(live, models.LiveGame{
+			GameID:      game.GameID,
+			Player1:     game.Player1,
+			Player2:     game.Player2,
+			CurrentTurn: game.CurrentTurn,
+			MoveCount:   game.MoveCount,
+			TimeControl: game.TimeControl.String(),
+			StartedAt:   game.StartedAt,
+		})
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].StartedAt.After(live[j].StartedAt) })
+	return live
+}
+
 func (gs *GameService) MakeMove(gameID uuid.UUID, playerID int, column int) (*models.MovePayload, *models.GameOverPayload, error) {
 	gs.gamesMutex.Lock()
 	defer gs.gamesMutex.Unlock()
@@ -88,26 +256,35 @@ func (gs *GameService) MakeMove(gameID uuid.UUID, playerID int, column int) (*mo
 	if currentPlayer.ID != playerID {
 		return nil, nil, errors.New("not your turn")
 	}
-	if !game.Board.IsValidMove(column) {
+	if !game.Position.IsValidMove(column) {
 		return nil, nil, errors.New("invalid move: column is full")
 	}
+	now := gs.clock.Now()
+	if !gs.stopClock(game, now) {
+		gs.endOnTime(game)
+		return nil, nil, errors.New("out of time")
+	}
 
 	playerNum := 1
 	if game.CurrentTurn == models.ColorYellow {
 		playerNum = 2
 	}
-	row := game.Board.DropDisc(column, playerNum)
+	row := playDisc(game, column, playerNum)
 	if row == -1 {
 		return nil, nil, errors.New("failed to drop disc")
 	}
 	game.MoveCount++
+	// Moving instead of answering declines a draw offer.
+	if game.DrawOfferedBy != playerID {
+		game.DrawOfferedBy = 0
+	}
 
 	_ = gs.db.SaveGameMove(gameID, playerID, column, row, game.MoveCount)
 
-	if game.Board.CheckWin(row, column) {
+	if game.Position.CheckWin(playerNum) {
 		return gs.handleGameEnd(game, &currentPlayer.ID, "win", column, row, currentPlayer.Color)
 	}
-	if game.Board.IsFull() {
+	if game.Position.IsFull() {
 		return gs.handleGameEnd(game, nil, "draw", column, row, currentPlayer.Color)
 	}
 
@@ -116,6 +293,7 @@ func (gs *GameService) MakeMove(gameID uuid.UUID, playerID int, column int) (*mo
 	} else {
 		game.CurrentTurn = models.ColorRed
 	}
+	gs.startTurn(game, now)
 
 	movePayload := &models.MovePayload{
 		Column:     column,
@@ -124,30 +302,62 @@ func (gs *GameService) MakeMove(gameID uuid.UUID, playerID int, column int) (*mo
 		NextTurn:   game.CurrentTurn,
 		Board:      game.Board,
 		MoveNumber: game.MoveCount,
+		Clocks:     clocksAt(game, now),
 	}
 	return movePayload, nil, nil
 }
 
 func (gs *GameService) MakeBotMove(gameID uuid.UUID) (*models.MovePayload, *models.GameOverPayload, error) {
-	gs.gamesMutex.Lock()
-	defer gs.gamesMutex.Unlock()
-
+	// The search runs on a copy of the position without holding gamesMutex,
+	// so other games carry on while the bot thinks.
+	gs.gamesMutex.RLock()
 	game, exists := gs.activeGames[gameID]
 	if !exists {
+		gs.gamesMutex.RUnlock()
 		return nil, nil, errors.New("game not found")
 	}
 	if game.Status != models.GameStatusActive {
+		gs.gamesMutex.RUnlock()
 		return nil, nil, errors.New("game is not active")
 	}
 	if !game.Player2.IsBot {
+		gs.gamesMutex.RUnlock()
 		return nil, nil, errors.New("player 2 is not a bot")
 	}
 	if game.CurrentTurn != game.Player2.Color {
+		gs.gamesMutex.RUnlock()
 		return nil, nil, errors.New("not bot's turn")
 	}
+	position, moveCount := game.Position, game.MoveCount
+	engine, fallback := gs.engineFor(game.Player2), gs.minimaxFor(game.Player2)
+	gs.gamesMutex.RUnlock()
+
+	// An engine that fails, such as an external one that has crashed, is
+	// replaced by the minimax bot for this move.
+	column, err := gs.searchMove(engine, position)
+	if err != nil && fallback.Name() != engine.Name() {
+		logger.Log.Warn("Bot engine failed, using minimax", zap.String("engine", engine.Name()), zap.Error(err))
+		column, err = gs.searchMove(fallback, position)
+	}
 
-	column := gs.bot.GetBestMove(game.Board)
-	row := game.Board.DropDisc(column, 2)
+	gs.gamesMutex.Lock()
+	defer gs.gamesMutex.Unlock()
+	// The game may have ended, by resignation or on time, during the search.
+	if game.Status != models.GameStatusActive || game.MoveCount != moveCount {
+		return nil, nil, errors.New("game changed while the bot was thinking")
+	}
+	// Rather than leave the game waiting on a bot that cannot move, the bot
+	// forfeits it.
+	if err != nil {
+		logger.Log.Error("Bot failed to move, forfeiting", zap.String("game_id", gameID.String()), zap.Error(err))
+		return nil, gs.forfeit(game, game.Player2.ID), nil
+	}
+	now := gs.clock.Now()
+	if !gs.stopClock(game, now) {
+		gs.endOnTime(game)
+		return nil, nil, errors.New("bot ran out of time")
+	}
+	row := playDisc(game, column, 2)
 	if row == -1 {
 		return nil, nil, errors.New("failed to drop disc")
 	}
@@ -155,14 +365,15 @@ func (gs *GameService) MakeBotMove(gameID uuid.UUID) (*models.MovePayload, *mode
 
 	_ = gs.db.SaveGameMove(gameID, game.Player2.ID, column, row, game.MoveCount)
 
-	if game.Board.CheckWin(row, column) {
+	if game.Position.CheckWin(2) {
 		return gs.handleGameEnd(game, &game.Player2.ID, "win", column, row, game.Player2.Color)
 	}
-	if game.Board.IsFull() {
+	if game.Position.IsFull() {
 		return gs.handleGameEnd(game, nil, "draw", column, row, game.Player2.Color)
 	}
 
 	game.CurrentTurn = models.ColorRed
+	gs.startTurn(game, now)
 
 	movePayload := &models.MovePayload{
 		Column:     column,
@@ -171,34 +382,67 @@ func (gs *GameService) MakeBotMove(gameID uuid.UUID) (*models.MovePayload, *mode
 		NextTurn:   game.CurrentTurn,
 		Board:      game.Board,
 		MoveNumber: game.MoveCount,
+		Clocks:     clocksAt(game, now),
 	}
 	return movePayload, nil, nil
 }
 
+// searchMove asks the engine for a move within the move timeout.
+func (gs *GameService) searchMove(engine bot.Engine, position models.Bitboard) (int, error) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if gs.moveTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, gs.moveTimeout)
+	}
+	defer cancel()
+	move, err := engine.BestMove(ctx, position)
+	if err != nil {
+		return 0, fmt.Errorf("%s failed to move: %w", engine.Name(), err)
+	}
+	if !position.IsValidMove(move.Column) {
+		return 0, fmt.Errorf("%s chose invalid column %d", engine.Name(), move.Column)
+	}
+	return move.Column, nil
+}
+
+// playDisc drops a disc on the game's bitboard and mirrors it into Board,
+// which is kept only for the JSON payloads.
+func playDisc(game *models.GameState, column, playerNum int) int {
+	row := game.Position.DropDisc(column, playerNum)
+	if row != -1 {
+		game.Board[row][column] = playerNum
+	}
+	return row
+}
+
 func (gs *GameService) handleGameEnd(game *models.GameState, winnerID *int, reason string, column int, row int, color models.PlayerColor) (*models.MovePayload, *models.GameOverPayload, error) {
-	completedAt := time.Now()
+	completedAt := gs.clock.Now()
 	game.CompletedAt = &completedAt
+	gs.stopTurnTimers(game.GameID)
 
 	var status models.GameStatus
-	if reason == "draw" {
+	switch reason {
+	case "draw", "agreed-draw":
 		status = models.GameStatusDraw
-		game.Status = status
-	} else if reason == "win" {
+	case "win":
 		status = models.GameStatusCompleted
-		game.Status = status
-		if winnerID != nil {
-			if *winnerID == game.Player1.ID {
-				game.Winner = &game.Player1.Username
-			} else {
-				game.Winner = &game.Player2.Username
-			}
-		}
-	} else if reason == "forfeit" {
+	case "forfeit":
 		status = models.GameStatusForfeited
-		game.Status = status
+	case "timeout":
+		status = models.GameStatusTimeout
+	case "resign":
+		status = models.GameStatusResigned
+	}
+	game.Status = status
+	if winnerID != nil {
+		if *winnerID == game.Player1.ID {
+			game.Winner = &game.Player1.Username
+		} else {
+			game.Winner = &game.Player2.Username
+		}
 	}
 
 	_ = gs.db.CompleteGame(game.GameID, winnerID, status, game.MoveCount, game.StartedAt)
+	gs.notifyGameEnd(game, winnerID)
 
 	movePayload := &models.MovePayload{
 		Column:     column,
@@ -226,7 +470,16 @@ func (gs *GameService) ForfeitGame(gameID uuid.UUID, playerID int) error {
 	if !exists {
 		return errors.New("game not found")
 	}
+	if game.Status != models.GameStatusActive {
+		return errors.New("game is not active")
+	}
+	gs.forfeit(game, playerID)
+	return nil
+}
 
+// forfeit ends the game as a loss for playerID. The caller must hold
+// gamesMutex.
+func (gs *GameService) forfeit(game *models.GameState, playerID int) *models.GameOverPayload {
 	var winnerID int
 	if game.Player1.ID == playerID {
 		winnerID = game.Player2.ID
@@ -234,9 +487,10 @@ func (gs *GameService) ForfeitGame(gameID uuid.UUID, playerID int) error {
 		winnerID = game.Player1.ID
 	}
 
-	completedAt := time.Now()
+	completedAt := gs.clock.Now()
 	game.CompletedAt = &completedAt
 	game.Status = models.GameStatusForfeited
+	gs.stopTurnTimers(game.GameID)
 
 	if winnerID == game.Player1.ID {
 		game.Winner = &game.Player1.Username
@@ -245,5 +499,349 @@ func (gs *GameService) ForfeitGame(gameID uuid.UUID, playerID int) error {
 	}
 
 	_ = gs.db.CompleteGame(game.GameID, &winnerID, models.GameStatusForfeited, game.MoveCount, game.StartedAt)
+	gs.notifyGameEnd(game, &winnerID)
+
+	return &models.GameOverPayload{
+		Winner:   game.Winner,
+		Reason:   "forfeit",
+		Board:    game.Board,
+		Duration: int(completedAt.Sub(game.StartedAt).Seconds()),
+	}
+}
+
+// Resign ends the game as a loss for playerID.
+func (gs *GameService) Resign(gameID uuid.UUID, playerID int) (*models.GameOverPayload, error) {
+	gs.gamesMutex.Lock()
+	defer gs.gamesMutex.Unlock()
+
+	game, player, opponent, err := gs.activeGameFor(gameID, playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = gs.db.SaveGameAction(gameID, player.ID, models.MoveActionResign, game.MoveCount+1)
+	_, gameOver, err := gs.handleGameEnd(game, &opponent.ID, "resign", -1, -1, player.Color)
+	logger.Log.Info("Player resigned", zap.String("game_id", gameID.String()), zap.String("username", player.Username))
+	return gameOver, err
+}
+
+// OfferDraw offers playerID's opponent a draw. The offer stands until the
+// opponent answers it or makes a move.
+func (gs *GameService) OfferDraw(gameID uuid.UUID, playerID int) error {
+	gs.gamesMutex.Lock()
+	defer gs.gamesMutex.Unlock()
+
+	game, _, opponent, err := gs.activeGameFor(gameID, playerID)
+	if err != nil {
+		return err
+	}
+	if opponent.IsBot {
+		return errors.New("the bot does not accept draws")
+	}
+	if game.DrawOfferedBy != 0 {
+		return errors.New("a draw has already been offered")
+	}
+	game.DrawOfferedBy = playerID
+	return nil
+}
+
+// RespondDraw answers the opponent's draw offer. Accepting ends the game
+// as a draw and returns its game-over payload; declining returns nil.
+func (gs *GameService) RespondDraw(gameID uuid.UUID, playerID int, accept bool) (*models.GameOverPayload, error) {
+	gs.gamesMutex.Lock()
+	defer gs.gamesMutex.Unlock()
+
+	game, player, opponent, err := gs.activeGameFor(gameID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if game.DrawOfferedBy != opponent.ID {
+		return nil, errors.New("no draw has been offered")
+	}
+	game.DrawOfferedBy = 0
+	if !accept {
+		return nil, nil
+	}
+
+	_ = gs.db.SaveGameAction(gameID, player.ID, models.MoveActionDraw, game.MoveCount+1)
+	_, gameOver, err := gs.handleGameEnd(game, nil, "agreed-draw", -1, -1, player.Color)
+	logger.Log.Info("Draw agreed", zap.String("game_id", gameID.String()))
+	return gameOver, err
+}
+
+// RequestRematch asks the opponent in a finished game to play again.
+func (gs *GameService) RequestRematch(gameID uuid.UUID, playerID int) error {
+	gs.gamesMutex.Lock()
+	defer gs.gamesMutex.Unlock()
+
+	game, _, opponent, err := gs.finishedGameFor(gameID, playerID)
+	if err != nil {
+		return err
+	}
+	if opponent.IsBot {
+		return errors.New("rematches are only for games between two players")
+	}
+	if game.RematchRequestedBy != 0 {
+		return errors.New("a rematch has already been requested")
+	}
+	game.RematchRequestedBy = playerID
 	return nil
 }
+
+// AcceptRematch starts the rematch the opponent asked for, with the colours
+// swapped.
+func (gs *GameService) AcceptRematch(gameID uuid.UUID, playerID int) (*models.GameState, error) {
+	gs.gamesMutex.Lock()
+	game, _, opponent, err := gs.finishedGameFor(gameID, playerID)
+	if err == nil && game.RematchRequestedBy != opponent.ID {
+		err = errors.New("no rematch has been requested")
+	}
+	if err != nil {
+		gs.gamesMutex.Unlock()
+		return nil, err
+	}
+	game.RematchRequestedBy = 0
+	red, yellow := game.Player2, game.Player1
+	timeControl := game.TimeControl
+	gs.gamesMutex.Unlock()
+
+	red.Color, yellow.Color = models.ColorRed, models.ColorYellow
+	rematch, err := gs.CreateTimedGame(red, yellow, timeControl)
+	if err != nil {
+		return nil, err
+	}
+
+	gs.gamesMutex.Lock()
+	game.RematchGameID = &rematch.GameID
+	gs.gamesMutex.Unlock()
+	logger.Log.Info("Rematch started", zap.String("game_id", gameID.String()), zap.String("rematch_id", rematch.GameID.String()))
+	return rematch, nil
+}
+
+// activeGameFor returns an active game with playerID and their opponent in
+// it. The caller must hold gamesMutex.
+func (gs *GameService) activeGameFor(gameID uuid.UUID, playerID int) (*models.GameState, models.PlayerInfo, models.PlayerInfo, error) {
+	game, player, opponent, err := gs.gameFor(gameID, playerID)
+	if err == nil && game.Status != models.GameStatusActive {
+		err = errors.New("game is not active")
+	}
+	return game, player, opponent, err
+}
+
+// finishedGameFor returns a finished game with playerID and their opponent
+// in it, as long as it has not been rematched. The caller must hold
+// gamesMutex.
+func (gs *GameService) finishedGameFor(gameID uuid.UUID, playerID int) (*models.GameState, models.PlayerInfo, models.PlayerInfo, error) {
+	game, player, opponent, err := gs.gameFor(gameID, playerID)
+	if err == nil && game.Status == models.GameStatusActive {
+		err = errors.New("game is still in progress")
+	}
+	if err == nil && game.RematchGameID != nil {
+		err = errors.New("game has already been rematched")
+	}
+	return game, player, opponent, err
+}
+
+func (gs *GameService) gameFor(gameID uuid.UUID, playerID int) (*models.GameState, models.PlayerInfo, models.PlayerInfo, error) {
+	game, exists := gs.activeGames[gameID]
+	if !exists {
+		return nil, models.PlayerInfo{}, models.PlayerInfo{}, errors.New("game not found")
+	}
+	switch playerID {
+	case game.Player1.ID:
+		return game, game.Player1, game.Player2, nil
+	case game.Player2.ID:
+		return game, game.Player2, game.Player1, nil
+	}
+	return nil, models.PlayerInfo{}, models.PlayerInfo{}, errors.New("you are not in this game")
+}
+
+// GetClocks returns the time each player has left in a timed game, or nil
+// if the game is untimed.
+func (gs *GameService) GetClocks(gameID uuid.UUID) *models.Clocks {
+	gs.gamesMutex.RLock()
+	defer gs.gamesMutex.RUnlock()
+	game, exists := gs.activeGames[gameID]
+	if !exists {
+		return nil
+	}
+	return clocksAt(game, gs.clock.Now())
+}
+
+// clockFor returns the clock of the given colour.
+func clockFor(game *models.GameState, color models.PlayerColor) *time.Duration {
+	if color == models.ColorYellow {
+		return &game.YellowTime
+	}
+	return &game.RedTime
+}
+
+// clocksAt reports both clocks as they stand at now, counting the side to
+// move's time used so far this turn.
+func clocksAt(game *models.GameState, now time.Time) *models.Clocks {
+	if !game.TimeControl.IsTimed() {
+		return nil
+	}
+	red, yellow := game.RedTime, game.YellowTime
+	if game.Status == models.GameStatusActive {
+		elapsed := now.Sub(game.TurnStartedAt)
+		if game.CurrentTurn == models.ColorRed {
+			red -= elapsed
+		} else {
+			yellow -= elapsed
+		}
+	}
+	return &models.Clocks{RedMs: max(red, 0).Milliseconds(), YellowMs: max(yellow, 0).Milliseconds()}
+}
+
+// startTurn starts the side to move's turn. In a timed game it starts
+// their clock, reset to the full allowance under a per-move time control,
+// and arms the timer that ends the game when it runs out. In an untimed game
+// it arms the idle timers instead. The caller must hold gamesMutex.
+func (gs *GameService) startTurn(game *models.GameState, now time.Time) {
+	gs.stopTurnTimers(game.GameID)
+	game.TurnStartedAt = now
+
+	if !game.TimeControl.IsTimed() {
+		gs.startIdleTimers(game)
+		return
+	}
+	clock := clockFor(game, game.CurrentTurn)
+	if game.TimeControl.PerMove > 0 {
+		*clock = game.TimeControl.PerMove
+	}
+	gs.afterTurn(game, *clock, gs.endOnTime)
+}
+
+// startIdleTimers warns a human player who has not moved for idleTimeout
+// minus idleWarning, and forfeits the game for them at idleTimeout. Bots
+// always move straight away. The caller must hold gamesMutex.
+func (gs *GameService) startIdleTimers(game *models.GameState) {
+	player := game.Player1
+	if game.CurrentTurn == game.Player2.Color {
+		player = game.Player2
+	}
+	if gs.idleTimeout <= 0 || player.IsBot {
+		return
+	}
+
+	if warnAfter := gs.idleTimeout - gs.idleWarning; gs.idleWarning > 0 && warnAfter > 0 {
+		gs.afterTurn(game, warnAfter, func(game *models.GameState) {
+			logger.Log.Info("Warning idle player", zap.String("game_id", game.GameID.String()), zap.String("username", player.Username))
+			if gs.onIdleWarningCallback != nil {
+				go gs.onIdleWarningCallback(game, player, gs.idleWarning)
+			}
+		})
+	}
+	gs.afterTurn(game, gs.idleTimeout, func(game *models.GameState) {
+		gameOver := gs.forfeit(game, player.ID)
+		logger.Log.Info("Player forfeited for inactivity", zap.String("game_id", game.GameID.String()), zap.String("username", player.Username))
+		if gs.onIdleForfeitCallback != nil {
+			go gs.onIdleForfeitCallback(game, gameOver)
+		}
+	})
+}
+
+// afterTurn runs f with gamesMutex held once d has passed, unless the turn
+// it was set for is over by then. The caller must hold gamesMutex.
+func (gs *GameService) afterTurn(game *models.GameState, d time.Duration, f func(game *models.GameState)) {
+	gameID, moveCount := game.GameID, game.MoveCount
+	timer := gs.clock.AfterFunc(d, func() {
+		gs.gamesMutex.Lock()
+		defer gs.gamesMutex.Unlock()
+		game, exists := gs.activeGames[gameID]
+		// The timer may have lost a race with the move it was waiting for.
+		if exists && game.Status == models.GameStatusActive && game.MoveCount == moveCount {
+			f(game)
+		}
+	})
+	gs.turnTimers[gameID] = append(gs.turnTimers[gameID], timer)
+}
+
+// stopClock charges the side to move for their turn and adds any
+// increment. It reports false if their time had already run out. The
+// caller must hold gamesMutex.
+func (gs *GameService) stopClock(game *models.GameState, now time.Time) bool {
+	if !game.TimeControl.IsTimed() {
+		return true
+	}
+	clock := clockFor(game, game.CurrentTurn)
+	*clock -= now.Sub(game.TurnStartedAt)
+	if *clock <= 0 {
+		*clock = 0
+		return false
+	}
+	*clock += game.TimeControl.Increment
+	return true
+}
+
+func (gs *GameService) stopTurnTimers(gameID uuid.UUID) {
+	for _, timer := range gs.turnTimers[gameID] {
+		timer.Stop()
+	}
+	delete(gs.turnTimers, gameID)
+}
+
+// endOnTime ends the game as a loss for the side to move and reports it
+// through the timeout callback. The caller must hold gamesMutex.
+func (gs *GameService) endOnTime(game *models.GameState) {
+	*clockFor(game, game.CurrentTurn) = 0
+	loser, winner := game.Player1, game.Player2
+	if game.CurrentTurn == game.Player2.Color {
+		loser, winner = game.Player2, game.Player1
+	}
+	_, gameOver, _ := gs.handleGameEnd(game, &winner.ID, "timeout", -1, -1, loser.Color)
+
+	logger.Log.Info("Player ran out of time", zap.String("game_id", game.GameID.String()), zap.String("username", loser.Username))
+	if gs.onTimeoutCallback != nil {
+		go gs.onTimeoutCallback(game, gameOver)
+	}
+}
+
+// updateRatings applies one Glicko-2 rating period to each human player.
+// Bot games rate the human against the bot difficulty's fixed rating and
+// leave the bot unrated.
+func (gs *GameService) updateRatings(game *models.GameState, winnerID *int) {
+	gs.ratingsMutex.Lock()
+	defer gs.ratingsMutex.Unlock()
+
+	score := rating.Draw
+	if winnerID != nil {
+		score = rating.Loss
+		if *winnerID == game.Player1.ID {
+			score = rating.Win
+		}
+	}
+
+	player1, err := gs.db.GetPlayerByID(game.Player1.ID)
+	if err != nil || player1 == nil {
+		logger.Log.Error("Failed to load player for rating", zap.Int("player_id", game.Player1.ID), zap.Error(err))
+		return
+	}
+	rating1 := playerRating(player1)
+
+	if game.Player2.IsBot {
+		opponent := rating.Fixed(bot.ProfileFor(bot.Difficulty(game.Player2.Difficulty)).Rating)
+		gs.saveRating(player1, game.GameID, rating.Update(rating1, []rating.Result{{Opponent: opponent, Score: score}}))
+		return
+	}
+
+	player2, err := gs.db.GetPlayerByID(game.Player2.ID)
+	if err != nil || player2 == nil {
+		logger.Log.Error("Failed to load player for rating", zap.Int("player_id", game.Player2.ID), zap.Error(err))
+		return
+	}
+	rating2 := playerRating(player2)
+	gs.saveRating(player1, game.GameID, rating.Update(rating1, []rating.Result{{Opponent: rating2, Score: score}}))
+	gs.saveRating(player2, game.GameID, rating.Update(rating2, []rating.Result{{Opponent: rating1, Score: 1 - score}}))
+}
+
+func (gs *GameService) saveRating(player *models.Player, gameID uuid.UUID, after rating.Rating) {
+	if err := gs.db.SaveRating(player.ID, gameID, player.Rating, after); err != nil {
+		logger.Log.Error("Failed to save rating", zap.String("username", player.Username), zap.Error(err))
+	}
+}
+
+func playerRating(p *models.Player) rating.Rating {
+	return rating.Rating{Value: p.Rating, Deviation: p.RatingDeviation, Volatility: p.RatingVolatility}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"connect4/internal/config"
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/internal/solver"
+	"connect4/pkg/logger"
+	"database/sql"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// Set once: game-end work carries on in the background after a test
+	// returns, and must not see the logger change under it.
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// fakeClock runs timers only when the test advances it.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasPending := !t.stopped
+	t.stopped = true
+	return wasPending
+}
+
+// Advance moves the clock on by d and runs the timers that come due, in
+// order, on the calling goroutine.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	for _, t := range c.timers {
+		if !t.stopped && !t.at.After(c.now) {
+			t.stopped = true
+			due = append(due, t)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+type idleWarning struct {
+	player    models.PlayerInfo
+	remaining time.Duration
+}
+
+type idleHarness struct {
+	gs       *GameService
+	clock    *fakeClock
+	warnings chan idleWarning
+	forfeits chan *models.GameOverPayload
+}
+
+// newIdleHarness builds a GameService with a 60 second idle timeout and a
+// 15 second warning. Its database is unreachable, which the game paths
+// under test only log.
+func newIdleHarness(t *testing.T) *idleHarness {
+	db, err := sql.Open("postgres", "postgres://127.0.0.1:1/connect4?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{Game: config.GameConfig{MoveIdleTimeout: 60, MoveIdleWarning: 15}}
+	h := &idleHarness{
+		gs:       NewGameService(database.Wrap(db), cfg, solver.New(nil), nil),
+		clock:    &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)},
+		warnings: make(chan idleWarning, 4),
+		forfeits: make(chan *models.GameOverPayload, 4),
+	}
+	h.gs.SetClock(h.clock)
+	h.gs.SetIdleWarningCallback(func(game *models.GameState, player models.PlayerInfo, remaining time.Duration) {
+		h.warnings <- idleWarning{player: player, remaining: remaining}
+	})
+	h.gs.SetIdleForfeitCallback(func(game *models.GameState, gameOver *models.GameOverPayload) {
+		h.forfeits <- gameOver
+	})
+	return h
+}
+
+// startGame puts a game straight into play, as CreateGame does once the
+// database has given it an ID.
+func (h *idleHarness) startGame(player2 models.PlayerInfo) *models.GameState {
+	game := &models.GameState{
+		GameID:      uuid.New(),
+		Player1:     models.PlayerInfo{ID: 1, Username: "alice", Color: models.ColorRed},
+		Player2:     player2,
+		Board:       models.NewBoard(),
+		Position:    models.NewBitboard(),
+		CurrentTurn: models.ColorRed,
+		Status:      models.GameStatusActive,
+		StartedAt:   h.clock.Now(),
+	}
+	h.gs.gamesMutex.Lock()
+	h.gs.activeGames[game.GameID] = game
+	h.gs.startTurn(game, game.StartedAt)
+	h.gs.gamesMutex.Unlock()
+	return game
+}
+
+func (h *idleHarness) status(t *testing.T, gameID uuid.UUID) models.GameStatus {
+	t.Helper()
+	game, err := h.gs.GetGameSnapshot(gameID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return game.Status
+}
+
+func (h *idleHarness) expectWarning(t *testing.T) idleWarning {
+	t.Helper()
+	select {
+	case w := <-h.warnings:
+		return w
+	case <-time.After(time.Second):
+		t.Fatal("no idle warning")
+		return idleWarning{}
+	}
+}
+
+func (h *idleHarness) expectForfeit(t *testing.T) *models.GameOverPayload {
+	t.Helper()
+	select {
+	case gameOver := <-h.forfeits:
+		return gameOver
+	case <-time.After(5 * time.Second):
+		t.Fatal("no idle forfeit")
+		return nil
+	}
+}
+
+func (h *idleHarness) expectQuiet(t *testing.T) {
+	t.Helper()
+	select {
+	case w := <-h.warnings:
+		t.Fatalf("unexpected warning for %s", w.player.Username)
+	case gameOver := <-h.forfeits:
+		t.Fatalf("unexpected forfeit, winner %v", gameOver.Winner)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+var bob = models.PlayerInfo{ID: 2, Username: "bob", Color: models.ColorYellow}
+
+func TestIdlePlayerIsWarnedThenForfeits(t *testing.T) {
+	h := newIdleHarness(t)
+	game := h.startGame(bob)
+
+	h.clock.Advance(44 * time.Second)
+	h.expectQuiet(t)
+
+	h.clock.Advance(time.Second)
+	w := h.expectWarning(t)
+	if w.player.Username != "alice" || w.remaining != 15*time.Second {
+		t.Fatalf("warning = %s with %v left, want alice with 15s", w.player.Username, w.remaining)
+	}
+	if status := h.status(t, game.GameID); status != models.GameStatusActive {
+		t.Fatalf("status after warning = %s, want active", status)
+	}
+
+	h.clock.Advance(15 * time.Second)
+	gameOver := h.expectForfeit(t)
+	if gameOver.Reason != "forfeit" || gameOver.Winner == nil || *gameOver.Winner != "bob" {
+		t.Fatalf("game over = %+v, want bob winning by forfeit", gameOver)
+	}
+	if status := h.status(t, game.GameID); status != models.GameStatusForfeited {
+		t.Fatalf("status = %s, want forfeited", status)
+	}
+}
+
+func TestIdleTimerRestartsAfterMove(t *testing.T) {
+	h := newIdleHarness(t)
+	game := h.startGame(bob)
+
+	h.clock.Advance(50 * time.Second)
+	h.expectWarning(t)
+	if _, _, err := h.gs.MakeMove(game.GameID, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice's timers are gone; bob has a full minute from his turn's start.
+	h.clock.Advance(44 * time.Second)
+	h.expectQuiet(t)
+	h.clock.Advance(time.Second)
+	if w := h.expectWarning(t); w.player.Username != "bob" {
+		t.Fatalf("warning for %s, want bob", w.player.Username)
+	}
+	h.clock.Advance(15 * time.Second)
+	if gameOver := h.expectForfeit(t); gameOver.Winner == nil || *gameOver.Winner != "alice" {
+		t.Fatalf("game over = %+v, want alice winning", gameOver)
+	}
+}
+
+func TestBotTurnHasNoIdleTimer(t *testing.T) {
+	h := newIdleHarness(t)
+	game := h.startGame(models.PlayerInfo{ID: 3, Username: "Bot_test", Color: models.ColorYellow, IsBot: true, Difficulty: "easy"})
+	if _, _, err := h.gs.MakeMove(game.GameID, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	h.gs.gamesMutex.RLock()
+	timers := len(h.gs.turnTimers[game.GameID])
+	h.gs.gamesMutex.RUnlock()
+	if timers != 0 {
+		t.Fatalf("bot turn has %d timers, want none", timers)
+	}
+
+	h.clock.Advance(5 * time.Minute)
+	h.expectQuiet(t)
+	if status := h.status(t, game.GameID); status != models.GameStatusActive {
+		t.Fatalf("status = %s, want active", status)
+	}
+}
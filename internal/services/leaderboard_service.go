@@ -1,22 +1,273 @@
 package services
 
 import (
+	"connect4/internal/config"
 	"connect4/internal/database"
+	"connect4/internal/leaderboard"
 	"connect4/internal/models"
+	"connect4/pkg/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type LeaderboardService struct {
 	db *database.Database
+
+	// The all-time leaderboards are kept in memory, loaded at startup,
+	// refreshed for the players of each game that ends and reconciled with
+	// the database now and then. Rolling periods are read from the
+	// database. cache is nil until the first load succeeds.
+	cache *leaderboardCache
+	// While a reconciliation is loading, players refreshed in the meantime
+	// are noted in dirty and refreshed again once it is swapped in, since
+	// the load may have read them before their game ended.
+	reconciling bool
+	dirty       map[int]bool
+	cacheMutex  sync.RWMutex
+}
+
+type boardKey struct {
+	sortBy      models.LeaderboardSort
+	excludeBots bool
+}
+
+type leaderboardCache struct {
+	boards    map[boardKey]*leaderboard.Board
+	stats     map[int]leaderboard.Stats
+	playerIDs map[string]int
+}
+
+func newLeaderboardCache(size int) *leaderboardCache {
+	c := &leaderboardCache{
+		boards:    make(map[boardKey]*leaderboard.Board),
+		stats:     make(map[int]leaderboard.Stats, size),
+		playerIDs: make(map[string]int, size),
+	}
+	for _, sortBy := range []models.LeaderboardSort{models.LeaderboardSortWins, models.LeaderboardSortRating} {
+		for _, excludeBots := range []bool{false, true} {
+			c.boards[boardKey{sortBy: sortBy, excludeBots: excludeBots}] = leaderboard.NewBoard(sortBy, excludeBots)
+		}
+	}
+	return c
+}
+
+// update puts a player's record on every board.
+func (c *leaderboardCache) update(s leaderboard.Stats) {
+	for _, board := range c.boards {
+		board.Update(s)
+	}
+	c.stats[s.ID] = s
+	c.playerIDs[s.Username] = s.ID
+}
+
+func NewLeaderboardService(db *database.Database, cfg *config.Config, gameService *GameService) *LeaderboardService {
+	ls := &LeaderboardService{db: db}
+	if err := ls.reconcile(); err != nil {
+		logger.Log.Error("Failed to load leaderboard cache", zap.Error(err))
+	}
+	gameService.AddGameEndCallback(ls.handleGameEnd)
+	go ls.runReconciliation(time.Duration(cfg.Game.LeaderboardReconcileInterval) * time.Second)
+	return ls
+}
+
+// GetLeaderboard returns a page of the leaderboard and how many players are
+// on it in all.
+func (ls *LeaderboardService) GetLeaderboard(q models.LeaderboardQuery) ([]models.LeaderboardEntry, int, error) {
+	ls.cacheMutex.RLock()
+	defer ls.cacheMutex.RUnlock()
+	if board := ls.cachedBoard(q); board != nil {
+		return board.Page(q.Offset, q.Limit), board.Len(), nil
+	}
+	return ls.db.GetLeaderboard(q)
+}
+
+// GetRank returns username's place on the leaderboard, or nil if they have
+// no games in its period.
+func (ls *LeaderboardService) GetRank(q models.LeaderboardQuery, username string) (*models.LeaderboardEntry, error) {
+	ls.cacheMutex.RLock()
+	defer ls.cacheMutex.RUnlock()
+	if board := ls.cachedBoard(q); board != nil {
+		playerID, exists := ls.cache.playerIDs[username]
+		if !exists {
+			return nil, nil
+		}
+		return board.Rank(playerID), nil
+	}
+	return ls.db.GetLeaderboardRank(q, username)
+}
+
+// cachedBoard returns the in-memory board for q, or nil if q must go to the
+// database. The caller must hold cacheMutex.
+func (ls *LeaderboardService) cachedBoard(q models.LeaderboardQuery) *leaderboard.Board {
+	if ls.cache == nil || q.Period != models.LeaderboardPeriodAllTime {
+		return nil
+	}
+	return ls.cache.boards[boardKey{sortBy: q.Sort, excludeBots: q.ExcludeBots}]
+}
+
+func (ls *LeaderboardService) handleGameEnd(game models.GameState) {
+	ids := []int64{int64(game.Player1.ID)}
+	if !game.Player2.IsBot {
+		ids = append(ids, int64(game.Player2.ID))
+	}
+	if err := ls.refresh(ids); err != nil {
+		logger.Log.Error("Failed to update leaderboard cache", zap.String("game_id", game.GameID.String()), zap.Error(err))
+	}
 }
 
-func NewLeaderboardService(db *database.Database) *LeaderboardService {
-	return &LeaderboardService{db: db}
+// refresh reloads the given players' records and moves them on every board.
+func (ls *LeaderboardService) refresh(ids []int64) error {
+	stats, err := ls.db.GetLeaderboardStats(ids)
+	if err != nil {
+		return err
+	}
+
+	ls.cacheMutex.Lock()
+	defer ls.cacheMutex.Unlock()
+	if ls.cache == nil {
+		return nil
+	}
+	for _, s := range stats {
+		ls.cache.update(s)
+		if ls.reconciling {
+			ls.dirty[s.ID] = true
+		}
+	}
+	return nil
 }
 
-func (ls *LeaderboardService) GetLeaderboard(limit int) ([]models.LeaderboardEntry, error) {
-	return ls.db.GetLeaderboard(limit)
+func (ls *LeaderboardService) runReconciliation(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := ls.reconcile(); err != nil {
+			logger.Log.Error("Failed to reconcile leaderboard cache", zap.Error(err))
+		}
+	}
+}
+
+// reconcile rebuilds the boards from the database and swaps them in,
+// logging how many players the cache had wrong.
+func (ls *LeaderboardService) reconcile() error {
+	ls.cacheMutex.Lock()
+	ls.reconciling = true
+	ls.dirty = make(map[int]bool)
+	ls.cacheMutex.Unlock()
+
+	all, err := ls.db.GetLeaderboardStats(nil)
+
+	ls.cacheMutex.Lock()
+	ls.reconciling = false
+	if err != nil {
+		ls.dirty = nil
+		ls.cacheMutex.Unlock()
+		return err
+	}
+
+	drift := 0
+	fresh := newLeaderboardCache(len(all))
+	for _, s := range all {
+		if ls.cache != nil {
+			if cached, exists := ls.cache.stats[s.ID]; !exists || !sameRecord(cached, s) {
+				drift++
+			}
+		}
+		fresh.update(s)
+	}
+	ls.cache = fresh
+
+	dirty := make([]int64, 0, len(ls.dirty))
+	for id := range ls.dirty {
+		dirty = append(dirty, int64(id))
+	}
+	ls.dirty = nil
+	ls.cacheMutex.Unlock()
+
+	if drift > 0 {
+		logger.Log.Warn("Leaderboard cache was out of date", zap.Int("players", drift))
+	}
+	if len(dirty) > 0 {
+		return ls.refresh(dirty)
+	}
+	return nil
+}
+
+func sameRecord(a, b leaderboard.Stats) bool {
+	return a.Username == b.Username && a.Rating == b.Rating && a.RatingDeviation == b.RatingDeviation &&
+		a.GamesWon == b.GamesWon && a.GamesPlayed == b.GamesPlayed &&
+		a.HumanGamesWon == b.HumanGamesWon && a.HumanGamesPlayed == b.HumanGamesPlayed
 }
 
 func (ls *LeaderboardService) GetPlayerStats(username string) (*models.Player, error) {
 	return ls.db.GetPlayerByUsername(username)
 }
+
+// GetRatingHistory returns the player's most recent rating changes, newest
+// first, or nil if the player does not exist.
+func (ls *LeaderboardService) GetRatingHistory(username string, limit int) ([]models.RatingChange, error) {
+	player, err := ls.db.GetPlayerByUsername(username)
+	if err != nil || player == nil {
+		return nil, err
+	}
+	history, err := ls.db.GetRatingHistory(player.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []models.RatingChange{}
+	}
+	return history, nil
+}
+
+// GetGameHistory returns a page of the player's games, newest first, and
+// the cursor for the next page, which is nil on the last page. The games
+// are nil if the player does not exist.
+func (ls *LeaderboardService) GetGameHistory(username string, filter models.GameHistoryFilter) ([]models.GameHistoryEntry, *models.GameCursor, error) {
+	player, err := ls.db.GetPlayerByUsername(username)
+	if err != nil || player == nil {
+		return nil, nil, err
+	}
+
+	limit := filter.Limit
+	filter.Limit++
+	games, err := ls.db.GetPlayerGames(player.ID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(games) <= limit {
+		return games, nil, nil
+	}
+	games = games[:limit]
+	last := games[limit-1]
+	return games, &models.GameCursor{StartedAt: last.StartedAt, GameID: last.GameID}, nil
+}
+
+// GetHeadToHead compares two players over all their games against each
+// other, with the most recent few. It returns nil if either player does not
+// exist.
+func (ls *LeaderboardService) GetHeadToHead(a, b string, recent int) (*models.HeadToHead, error) {
+	playerA, err := ls.db.GetPlayerByUsername(a)
+	if err != nil || playerA == nil {
+		return nil, err
+	}
+	playerB, err := ls.db.GetPlayerByUsername(b)
+	if err != nil || playerB == nil {
+		return nil, err
+	}
+
+	h, err := ls.db.GetHeadToHead(playerA.ID, playerB.ID)
+	if err != nil {
+		return nil, err
+	}
+	h.PlayerA, h.PlayerB = playerA.Username, playerB.Username
+	h.RecentGames, err = ls.db.GetPlayerGames(playerA.ID, models.GameHistoryFilter{Opponent: playerB.Username, Limit: recent})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
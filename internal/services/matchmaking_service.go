@@ -1,16 +1,22 @@
 package services
+
 import (
 	"connect4/internal/config"
 	"connect4/internal/database"
 	"connect4/internal/models"
 	"connect4/pkg/logger"
 	"errors"
+	"math"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// sweepInterval is how often waiting players are re-checked against each
+// other as their rating windows widen.
+const sweepInterval = time.Second
+
 type MatchmakingService struct {
 	db              *database.Database
 	config          *config.Config
@@ -22,12 +28,14 @@ type MatchmakingService struct {
 }
 
 func NewMatchmakingService(db *database.Database, cfg *config.Config, gameService *GameService) *MatchmakingService {
-	return &MatchmakingService{
+	ms := &MatchmakingService{
 		db:           db,
 		config:       cfg,
 		waitingQueue: make([]*models.WaitingPlayer, 0),
 		gameService:  gameService,
 	}
+	go ms.runSweeps()
+	return ms
 }
 
 func (ms *MatchmakingService) SetMatchCallback(callback func(player1, player2 *models.WaitingPlayer, gameState *models.GameState)) {
@@ -38,7 +46,18 @@ func (ms *MatchmakingService) SetBotCallback(callback func(player *models.Waitin
 	ms.onBotCallback = callback
 }
 
-func (ms *MatchmakingService) JoinQueue(username, socketID string) error {
+// JoinQueue adds a player looking for a game under the given time control,
+// or the configured default if it is empty. Players are only matched with
+// others who chose the same time control.
+func (ms *MatchmakingService) JoinQueue(username, socketID, botDifficulty, timeControl string) error {
+	if timeControl == "" {
+		timeControl = ms.config.Game.DefaultTimeControl
+	}
+	tc, err := models.ParseTimeControl(timeControl)
+	if err != nil {
+		return err
+	}
+
 	ms.queueMutex.Lock()
 	defer ms.queueMutex.Unlock()
 
@@ -60,24 +79,22 @@ func (ms *MatchmakingService) JoinQueue(username, socketID string) error {
 	}
 
 	waitingPlayer := &models.WaitingPlayer{
-		Username:  username,
-		PlayerID:  player.ID,
-		SocketID:  socketID,
-		JoinedAt:  time.Now(),
-		TimerDone: false,
-	}
-
-	if len(ms.waitingQueue) > 0 {
-		opponent := ms.waitingQueue[0]
-		ms.waitingQueue = ms.waitingQueue[1:]
-		go ms.createMatch(opponent, waitingPlayer)
-		logger.Log.Info("Players matched", zap.String("player1", opponent.Username), zap.String("player2", waitingPlayer.Username))
-		return nil
+		Username:      username,
+		PlayerID:      player.ID,
+		SocketID:      socketID,
+		BotDifficulty: botDifficulty,
+		Rating:        player.Rating,
+		TimeControl:   tc,
+		JoinedAt:      time.Now(),
+		TimerDone:     false,
 	}
 
 	ms.waitingQueue = append(ms.waitingQueue, waitingPlayer)
+	if ms.tryMatch(len(ms.waitingQueue)-1, time.Now()) {
+		return nil
+	}
 	go ms.startBotTimer(waitingPlayer)
-	logger.Log.Info("Player joined matchmaking queue", zap.String("username", username))
+	logger.Log.Info("Player joined matchmaking queue", zap.String("username", username), zap.Float64("rating", waitingPlayer.Rating))
 	return nil
 }
 
@@ -90,14 +107,98 @@ func (ms *MatchmakingService) startBotTimer(player *models.WaitingPlayer) {
 
 	for i, p := range ms.waitingQueue {
 		if p.Username == player.Username && !p.TimerDone {
+			// One last look for a human before falling back to the bot.
+			if ms.tryMatch(i, time.Now()) {
+				return
+			}
 			ms.waitingQueue = append(ms.waitingQueue[:i], ms.waitingQueue[i+1:]...)
 			go ms.createBotMatch(player)
-			logger.Log.Info("Matchmaking timeout - starting bot game", zap.String("player", player.Username))
+			logger.Log.Info("Matchmaking timeout - starting bot game", zap.String("player", player.Username), zap.String("difficulty", player.BotDifficulty))
 			return
 		}
 	}
 }
 
+// ratingWindow is how far from their own rating a player will accept an
+// opponent. It starts narrow and widens the longer they wait.
+func (ms *MatchmakingService) ratingWindow(player *models.WaitingPlayer, now time.Time) float64 {
+	cfg := ms.config.Game
+	window := float64(cfg.MatchmakingRatingWindow) + float64(cfg.MatchmakingWindowGrowth)*now.Sub(player.JoinedAt).Seconds()
+	return math.Min(window, float64(cfg.MatchmakingMaxWindow))
+}
+
+// tryMatch pairs the player at index i with the closest-rated queued player
+// whose window, like i's own, covers the rating gap. The longer-waiting
+// player plays red. The caller must hold queueMutex.
+func (ms *MatchmakingService) tryMatch(i int, now time.Time) bool {
+	player := ms.waitingQueue[i]
+	best := -1
+	bestGap := math.Inf(1)
+	for j, candidate := range ms.waitingQueue {
+		if j == i || candidate.TimeControl != player.TimeControl {
+			continue
+		}
+		gap := math.Abs(player.Rating - candidate.Rating)
+		if gap > ms.ratingWindow(player, now) || gap > ms.ratingWindow(candidate, now) {
+			continue
+		}
+		if gap < bestGap {
+			best, bestGap = j, gap
+		}
+	}
+	if best == -1 {
+		return false
+	}
+
+	opponent := ms.waitingQueue[best]
+	player1, player2 := opponent, player
+	if player.JoinedAt.Before(opponent.JoinedAt) {
+		player1, player2 = player, opponent
+	}
+	ms.removeFromQueue(player)
+	ms.removeFromQueue(opponent)
+	go ms.createMatch(player1, player2)
+	logger.Log.Info("Players matched", zap.String("player1", player1.Username), zap.String("player2", player2.Username), zap.Float64("rating_gap", bestGap))
+	return true
+}
+
+func (ms *MatchmakingService) removeFromQueue(player *models.WaitingPlayer) {
+	if i := ms.queueIndex(player); i >= 0 {
+		ms.waitingQueue = append(ms.waitingQueue[:i], ms.waitingQueue[i+1:]...)
+	}
+}
+
+// queueIndex returns where player is in the queue, or -1 if they have left
+// it. The caller must hold queueMutex.
+func (ms *MatchmakingService) queueIndex(player *models.WaitingPlayer) int {
+	for i, p := range ms.waitingQueue {
+		if p == player {
+			return i
+		}
+	}
+	return -1
+}
+
+// runSweeps periodically retries matching everyone in the queue, longest
+// waiting first, so widening windows can pair players who were too far
+// apart when they joined. A match can take out a player anywhere in the
+// queue, so the sweep walks a copy and looks each player up again.
+func (ms *MatchmakingService) runSweeps() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ms.queueMutex.Lock()
+		now := time.Now()
+		queued := append([]*models.WaitingPlayer(nil), ms.waitingQueue...)
+		for _, player := range queued {
+			if i := ms.queueIndex(player); i >= 0 {
+				ms.tryMatch(i, now)
+			}
+		}
+		ms.queueMutex.Unlock()
+	}
+}
+
 func (ms *MatchmakingService) createMatch(player1, player2 *models.WaitingPlayer) {
 	player1Info := models.PlayerInfo{
 		ID:       player1.PlayerID,
@@ -113,7 +214,7 @@ func (ms *MatchmakingService) createMatch(player1, player2 *models.WaitingPlayer
 		IsBot:    false,
 		SocketID: player2.SocketID,
 	}
-	gameState, err := ms.gameService.CreateGame(player1Info, player2Info)
+	gameState, err := ms.gameService.CreateTimedGame(player1Info, player2Info, player1.TimeControl)
 	if err != nil {
 		logger.Log.Error("Failed to create game", zap.Error(err))
 		return
@@ -137,12 +238,14 @@ func (ms *MatchmakingService) createBotMatch(player *models.WaitingPlayer) {
 		return
 	}
 	botInfo := models.PlayerInfo{
-		ID:       botPlayer.ID,
-		Username: "Bot",
-		Color:    models.ColorYellow,
-		IsBot:    true,
+		ID:         botPlayer.ID,
+		Username:   "Bot",
+		Color:      models.ColorYellow,
+		IsBot:      true,
+		Difficulty: player.BotDifficulty,
+		Engine:     ms.config.Game.BotEngine,
 	}
-	gameState, err := ms.gameService.CreateGame(playerInfo, botInfo)
+	gameState, err := ms.gameService.CreateTimedGame(playerInfo, botInfo, player.TimeControl)
 	if err != nil {
 		logger.Log.Error("Failed to create bot game", zap.Error(err))
 		return
@@ -162,4 +265,3 @@ func (ms *MatchmakingService) LeaveQueue(username string) {
 		}
 	}
 }
-
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrMoveOutOfRange = errors.New("move is out of range")
+
+type ReplayService struct {
+	db *database.Database
+}
+
+func NewReplayService(db *database.Database) *ReplayService {
+	return &ReplayService{db: db}
+}
+
+// GetGame returns a stored game with its moves, or nil if there is no such
+// game.
+func (rs *ReplayService) GetGame(gameID uuid.UUID) (*models.GameRecord, error) {
+	return rs.db.GetGameRecord(gameID)
+}
+
+// GetPosition rebuilds the board after the first move discs of a stored
+// game, or returns nil if there is no such game. Move 0 is the empty board
+// and a negative move the final one.
+func (rs *ReplayService) GetPosition(gameID uuid.UUID, move int) (*models.ReplayPosition, error) {
+	record, err := rs.db.GetGameRecord(gameID)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	var discs []models.GameMove
+	for _, m := range record.Moves {
+		if m.Action == models.MoveActionMove {
+			discs = append(discs, m)
+		}
+	}
+	if move < 0 {
+		move = len(discs)
+	}
+	if move > len(discs) {
+		return nil, ErrMoveOutOfRange
+	}
+
+	position := &models.ReplayPosition{
+		GameID:     gameID,
+		MoveNumber: move,
+		TotalMoves: len(discs),
+		Board:      models.NewBoard(),
+		NextTurn:   models.ColorRed,
+	}
+	for i := 0; i < move; i++ {
+		m := discs[i]
+		playerNum := 2
+		if m.Color == models.ColorRed {
+			playerNum = 1
+		}
+		position.Board[*m.Row][*m.Column] = playerNum
+		position.LastMove = &discs[i]
+	}
+	if move%2 == 1 {
+		position.NextTurn = models.ColorYellow
+	}
+	return position, nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"connect4/internal/config"
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/pkg/logger"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrHostNotInRoom = errors.New("host is not in the room")
+)
+
+const (
+	// roomCodeAlphabet leaves out characters that are easy to misread.
+	roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	roomCodeLength   = 6
+	// roomSweepInterval is how often idle rooms are checked for expiry.
+	roomSweepInterval = 5 * time.Second
+)
+
+type RoomService struct {
+	db               *database.Database
+	config           *config.Config
+	gameService      *GameService
+	rooms            map[string]*models.Room
+	roomsMutex       sync.Mutex
+	onStartCallback  func(host, guest *models.WaitingPlayer, gameState *models.GameState)
+	onExpireCallback func(room *models.Room)
+}
+
+func NewRoomService(db *database.Database, cfg *config.Config, gameService *GameService) *RoomService {
+	rs := &RoomService{
+		db:          db,
+		config:      cfg,
+		gameService: gameService,
+		rooms:       make(map[string]*models.Room),
+	}
+	go rs.runExpiry()
+	return rs
+}
+
+func (rs *RoomService) SetStartCallback(callback func(host, guest *models.WaitingPlayer, gameState *models.GameState)) {
+	rs.onStartCallback = callback
+}
+
+func (rs *RoomService) SetExpireCallback(callback func(room *models.Room)) {
+	rs.onExpireCallback = callback
+}
+
+// CreateRoom opens a room hosted by username, replacing any room they
+// already host. A non-empty socketID puts the host in the lobby straight
+// away; rooms created over REST wait for the host to join by code.
+func (rs *RoomService) CreateRoom(username, socketID string) (*models.Room, error) {
+	player, err := rs.db.GetPlayerByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		player, err = rs.db.CreatePlayer(username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rs.roomsMutex.Lock()
+	defer rs.roomsMutex.Unlock()
+
+	for code, room := range rs.rooms {
+		if room.Host == username {
+			delete(rs.rooms, code)
+		}
+	}
+
+	code, err := rs.newCode()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	room := &models.Room{
+		Code:         code,
+		Host:         username,
+		HostID:       player.ID,
+		HostSocketID: socketID,
+		HostInLobby:  socketID != "",
+		CreatedAt:    now,
+	}
+	rs.touch(room, now)
+	rs.rooms[code] = room
+
+	logger.Log.Info("Room created", zap.String("code", code), zap.String("host", username))
+	copied := *room
+	return &copied, nil
+}
+
+// JoinRoom enters the room with the given code. The host joining puts them
+// in the lobby; anyone else joining starts the game against the host, who
+// plays red.
+func (rs *RoomService) JoinRoom(code, username, socketID string) (*models.Room, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	rs.roomsMutex.Lock()
+	room, exists := rs.rooms[code]
+	if !exists {
+		rs.roomsMutex.Unlock()
+		return nil, ErrRoomNotFound
+	}
+	if room.Host == username {
+		room.HostSocketID = socketID
+		room.HostInLobby = true
+		rs.touch(room, time.Now())
+		copied := *room
+		rs.roomsMutex.Unlock()
+		logger.Log.Info("Host entered room", zap.String("code", code), zap.String("host", username))
+		return &copied, nil
+	}
+	if !room.HostInLobby {
+		rs.roomsMutex.Unlock()
+		return nil, ErrHostNotInRoom
+	}
+	delete(rs.rooms, code)
+	rs.roomsMutex.Unlock()
+
+	if err := rs.startGame(room, username, socketID); err != nil {
+		rs.roomsMutex.Lock()
+		rs.rooms[code] = room
+		rs.roomsMutex.Unlock()
+		return nil, err
+	}
+	return room, nil
+}
+
+// GetRoom returns the room with the given code, or nil if there is none.
+func (rs *RoomService) GetRoom(code string) *models.Room {
+	rs.roomsMutex.Lock()
+	defer rs.roomsMutex.Unlock()
+	room, exists := rs.rooms[strings.ToUpper(strings.TrimSpace(code))]
+	if !exists {
+		return nil
+	}
+	copied := *room
+	return &copied
+}
+
+// LeaveLobby takes a disconnected host out of their room's lobby. The room
+// stays open until it expires, so the host can come back with the code.
+func (rs *RoomService) LeaveLobby(username string) {
+	rs.roomsMutex.Lock()
+	defer rs.roomsMutex.Unlock()
+	for _, room := range rs.rooms {
+		if room.Host == username && room.HostInLobby {
+			room.HostInLobby = false
+			room.HostSocketID = ""
+			rs.touch(room, time.Now())
+		}
+	}
+}
+
+func (rs *RoomService) startGame(room *models.Room, username, socketID string) error {
+	player, err := rs.db.GetPlayerByUsername(username)
+	if err != nil {
+		return err
+	}
+	if player == nil {
+		player, err = rs.db.CreatePlayer(username)
+		if err != nil {
+			return err
+		}
+	}
+
+	hostInfo := models.PlayerInfo{
+		ID:       room.HostID,
+		Username: room.Host,
+		Color:    models.ColorRed,
+		IsBot:    false,
+		SocketID: room.HostSocketID,
+	}
+	guestInfo := models.PlayerInfo{
+		ID:       player.ID,
+		Username: username,
+		Color:    models.ColorYellow,
+		IsBot:    false,
+		SocketID: socketID,
+	}
+	gameState, err := rs.gameService.CreateGame(hostInfo, guestInfo)
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Info("Room game started", zap.String("code", room.Code), zap.String("host", room.Host), zap.String("guest", username))
+	if rs.onStartCallback != nil {
+		now := time.Now()
+		host := &models.WaitingPlayer{Username: room.Host, PlayerID: room.HostID, SocketID: room.HostSocketID, JoinedAt: room.CreatedAt}
+		guest := &models.WaitingPlayer{Username: username, PlayerID: player.ID, SocketID: socketID, Rating: player.Rating, JoinedAt: now}
+		rs.onStartCallback(host, guest, gameState)
+	}
+	return nil
+}
+
+// touch records activity in the room, pushing back its expiry.
+func (rs *RoomService) touch(room *models.Room, now time.Time) {
+	room.ExpiresAt = now.Add(time.Duration(rs.config.Game.RoomIdleTimeout) * time.Second)
+}
+
+// newCode returns an unused invite code. The caller must hold roomsMutex.
+func (rs *RoomService) newCode() (string, error) {
+	buf := make([]byte, roomCodeLength)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate room code: %w", err)
+		}
+		for i, b := range buf {
+			buf[i] = roomCodeAlphabet[int(b)%len(roomCodeAlphabet)]
+		}
+		code := string(buf)
+		if _, taken := rs.rooms[code]; !taken {
+			return code, nil
+		}
+	}
+}
+
+func (rs *RoomService) runExpiry() {
+	ticker := time.NewTicker(roomSweepInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		var expired []*models.Room
+		rs.roomsMutex.Lock()
+		for code, room := range rs.rooms {
+			if now.After(room.ExpiresAt) {
+				delete(rs.rooms, code)
+				expired = append(expired, room)
+			}
+		}
+		rs.roomsMutex.Unlock()
+
+		for _, room := range expired {
+			logger.Log.Info("Room expired", zap.String("code", room.Code), zap.String("host", room.Host))
+			if rs.onExpireCallback != nil {
+				rs.onExpireCallback(room)
+			}
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"connect4/internal/database"
+	"connect4/internal/models"
+	"connect4/internal/tournaments"
+	"connect4/pkg/logger"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type TournamentService struct {
+	db          *database.Database
+	gameService *GameService
+	// roundMutex serialises starting tournaments and recording results so a
+	// round is only advanced once.
+	roundMutex          sync.Mutex
+	onGameStartCallback func(tournament *tournaments.Tournament, pairing tournaments.Pairing, gameState *models.GameState)
+}
+
+func NewTournamentService(db *database.Database, gameService *GameService) *TournamentService {
+	ts := &TournamentService{
+		db:          db,
+		gameService: gameService,
+	}
+	gameService.AddGameEndCallback(ts.handleGameEnd)
+	return ts
+}
+
+func (ts *TournamentService) SetGameStartCallback(callback func(tournament *tournaments.Tournament, pairing tournaments.Pairing, gameState *models.GameState)) {
+	ts.onGameStartCallback = callback
+}
+
+// Create opens a tournament for registration. Rounds only applies to Swiss;
+// zero picks a default when the tournament starts.
+func (ts *TournamentService) Create(name string, format tournaments.Format, rounds int, username string) (*tournaments.Tournament, error) {
+	creator, err := ts.db.GetPlayerByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if creator == nil {
+		creator, err = ts.db.CreatePlayer(username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := ts.db.CreateTournament(name, format, rounds, creator.ID)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Tournament created", zap.Int("tournament_id", id), zap.String("format", string(format)), zap.String("created_by", username))
+	return ts.db.GetTournament(id)
+}
+
+func (ts *TournamentService) Join(tournamentID int, username string) error {
+	tournament, err := ts.db.GetTournament(tournamentID)
+	if err != nil {
+		return err
+	}
+	if tournament == nil {
+		return tournaments.ErrNotFound
+	}
+	if tournament.Status != tournaments.StatusRegistering {
+		return tournaments.ErrNotRegistering
+	}
+
+	player, err := ts.db.GetPlayerByUsername(username)
+	if err != nil {
+		return err
+	}
+	if player == nil {
+		player, err = ts.db.CreatePlayer(username)
+		if err != nil {
+			return err
+		}
+	}
+
+	added, err := ts.db.AddTournamentPlayer(tournamentID, player.ID)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return tournaments.ErrAlreadyJoined
+	}
+	logger.Log.Info("Player joined tournament", zap.Int("tournament_id", tournamentID), zap.String("username", username))
+	return nil
+}
+
+// Start seeds the players by rating and starts the first round. Only the
+// tournament's creator may start it. Starting a running tournament again
+// creates any games of the current round that failed to start.
+func (ts *TournamentService) Start(tournamentID int, username string) error {
+	ts.roundMutex.Lock()
+	defer ts.roundMutex.Unlock()
+
+	tournament, err := ts.db.GetTournament(tournamentID)
+	if err != nil {
+		return err
+	}
+	if tournament == nil {
+		return tournaments.ErrNotFound
+	}
+	if tournament.CreatedBy != username {
+		return tournaments.ErrNotCreator
+	}
+	if tournament.Status == tournaments.StatusRunning {
+		return ts.resumeRound(tournament)
+	}
+	if tournament.Status != tournaments.StatusRegistering {
+		return tournaments.ErrNotRegistering
+	}
+
+	players, err := ts.db.GetTournamentPlayers(tournamentID)
+	if err != nil {
+		return err
+	}
+	if len(players) < 2 {
+		return tournaments.ErrNotEnoughPlayers
+	}
+	sort.SliceStable(players, func(i, j int) bool { return players[i].Rating > players[j].Rating })
+	for i := range players {
+		players[i].Seed = i + 1
+	}
+
+	tournament.Rounds = tournaments.RoundsFor(tournament.Format, len(players), tournament.Rounds)
+	pairings, err := tournaments.Pair(tournament.Format, 1, players, nil)
+	if err != nil {
+		return err
+	}
+	if err := ts.db.StartTournament(tournamentID, tournament.Rounds, players, pairings); err != nil {
+		return err
+	}
+	tournament.Status = tournaments.StatusRunning
+	tournament.CurrentRound = 1
+
+	logger.Log.Info("Tournament started", zap.Int("tournament_id", tournamentID), zap.Int("players", len(players)), zap.Int("rounds", tournament.Rounds))
+	return ts.startGames(tournament, players, pairings)
+}
+
+// resumeRound creates the games of the current round that have none yet.
+// The caller must hold roundMutex.
+func (ts *TournamentService) resumeRound(tournament *tournaments.Tournament) error {
+	pairings, err := ts.db.GetTournamentPairings(tournament.ID)
+	if err != nil {
+		return err
+	}
+	var missing []tournaments.Pairing
+	for _, p := range pairings {
+		if p.Round == tournament.CurrentRound && p.Result == tournaments.ResultPending && p.GameID == nil {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return tournaments.ErrNotRegistering
+	}
+	players, err := ts.db.GetTournamentPlayers(tournament.ID)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Resuming tournament round", zap.Int("tournament_id", tournament.ID), zap.Int("round", tournament.CurrentRound), zap.Int("boards", len(missing)))
+	return ts.startGames(tournament, players, missing)
+}
+
+// Get returns the tournament with its players, pairings and standings, or
+// nil if there is no such tournament.
+func (ts *TournamentService) Get(tournamentID int) (*tournaments.Summary, error) {
+	tournament, err := ts.db.GetTournament(tournamentID)
+	if err != nil || tournament == nil {
+		return nil, err
+	}
+	players, err := ts.db.GetTournamentPlayers(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	pairings, err := ts.db.GetTournamentPairings(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	return &tournaments.Summary{
+		Tournament: tournament,
+		Players:    players,
+		Pairings:   pairings,
+		Standings:  tournaments.Standings(players, pairings),
+	}, nil
+}
+
+func (ts *TournamentService) List(status tournaments.Status, limit int) ([]tournaments.Tournament, error) {
+	return ts.db.ListTournaments(status, limit)
+}
+
+// handleGameEnd records the result of a tournament game and starts the next
+// round once every game of the current one is done. A drawn knockout game
+// is replayed with colours swapped.
+func (ts *TournamentService) handleGameEnd(game models.GameState) {
+	ts.roundMutex.Lock()
+	defer ts.roundMutex.Unlock()
+
+	pairing, err := ts.db.GetPairingByGame(game.GameID)
+	if err != nil {
+		logger.Log.Error("Failed to look up tournament pairing", zap.String("game_id", game.GameID.String()), zap.Error(err))
+		return
+	}
+	if pairing == nil || pairing.Result != tournaments.ResultPending {
+		return
+	}
+	tournament, err := ts.db.GetTournament(pairing.TournamentID)
+	if err != nil || tournament == nil || tournament.Status != tournaments.StatusRunning {
+		return
+	}
+
+	result := tournaments.ResultDraw
+	if game.Winner != nil {
+		winnerID := game.Player2.ID
+		if *game.Winner == game.Player1.Username {
+			winnerID = game.Player1.ID
+		}
+		result = tournaments.ResultPlayer2
+		if winnerID == pairing.Player1 {
+			result = tournaments.ResultPlayer1
+		}
+	}
+
+	if result == tournaments.ResultDraw && tournament.Format == tournaments.FormatKnockout {
+		if err := ts.replay(tournament, *pairing, game); err != nil {
+			logger.Log.Error("Failed to replay knockout game", zap.Int("tournament_id", tournament.ID), zap.Error(err))
+		}
+		return
+	}
+
+	if err := ts.db.SetPairingResult(pairing.ID, result); err != nil {
+		logger.Log.Error("Failed to record tournament result", zap.Int("tournament_id", tournament.ID), zap.Error(err))
+		return
+	}
+
+	pairings, err := ts.db.GetTournamentPairings(tournament.ID)
+	if err != nil {
+		logger.Log.Error("Failed to load tournament pairings", zap.Int("tournament_id", tournament.ID), zap.Error(err))
+		return
+	}
+	for _, p := range pairings {
+		if p.Round == tournament.CurrentRound && p.Result == tournaments.ResultPending {
+			return
+		}
+	}
+
+	if tournament.CurrentRound >= tournament.Rounds {
+		if err := ts.db.FinishTournament(tournament.ID); err != nil {
+			logger.Log.Error("Failed to finish tournament", zap.Int("tournament_id", tournament.ID), zap.Error(err))
+			return
+		}
+		logger.Log.Info("Tournament finished", zap.Int("tournament_id", tournament.ID))
+		return
+	}
+
+	players, err := ts.db.GetTournamentPlayers(tournament.ID)
+	if err != nil {
+		logger.Log.Error("Failed to load tournament players", zap.Int("tournament_id", tournament.ID), zap.Error(err))
+		return
+	}
+	if err := ts.startRound(tournament, tournament.CurrentRound+1, players, pairings); err != nil {
+		logger.Log.Error("Failed to start tournament round", zap.Int("tournament_id", tournament.ID), zap.Error(err))
+	}
+}
+
+// startRound pairs the round, saves it and then starts its games. The
+// caller must hold roundMutex.
+func (ts *TournamentService) startRound(tournament *tournaments.Tournament, round int, players []tournaments.Player, history []tournaments.Pairing) error {
+	pairings, err := tournaments.Pair(tournament.Format, round, players, history)
+	if err != nil {
+		return err
+	}
+	if err := ts.db.SaveTournamentRound(tournament.ID, round, pairings); err != nil {
+		return err
+	}
+	tournament.CurrentRound = round
+
+	logger.Log.Info("Tournament round started", zap.Int("tournament_id", tournament.ID), zap.Int("round", round), zap.Int("boards", len(pairings)))
+	return ts.startGames(tournament, players, pairings)
+}
+
+// startGames creates and announces a game for each saved pairing that is
+// not a bye, attaching it to the pairing as soon as it exists. It stops at
+// the first failure; Start picks up the pairings left without a game. The
+// caller must hold roundMutex.
+func (ts *TournamentService) startGames(tournament *tournaments.Tournament, players []tournaments.Player, pairings []tournaments.Pairing) error {
+	byID := make(map[int]tournaments.Player, len(players))
+	for _, p := range players {
+		byID[p.ID] = p
+	}
+	for _, p := range pairings {
+		if p.IsBye() {
+			continue
+		}
+		gameState, err := ts.createGame(byID[p.Player1], byID[p.Player2])
+		if err != nil {
+			return err
+		}
+		if err := ts.db.SetPairingGame(p.ID, gameState.GameID); err != nil {
+			return err
+		}
+		p.GameID = &gameState.GameID
+		if ts.onGameStartCallback != nil {
+			ts.onGameStartCallback(tournament, p, gameState)
+		}
+	}
+	return nil
+}
+
+// replay starts a new game for a drawn knockout pairing, with the colours
+// of the drawn game reversed.
+func (ts *TournamentService) replay(tournament *tournaments.Tournament, pairing tournaments.Pairing, drawn models.GameState) error {
+	red := tournaments.Player{ID: drawn.Player2.ID, Username: drawn.Player2.Username}
+	yellow := tournaments.Player{ID: drawn.Player1.ID, Username: drawn.Player1.Username}
+	gameState, err := ts.createGame(red, yellow)
+	if err != nil {
+		return err
+	}
+	if err := ts.db.SetPairingGame(pairing.ID, gameState.GameID); err != nil {
+		return err
+	}
+	pairing.GameID = &gameState.GameID
+
+	logger.Log.Info("Knockout game drawn, replaying", zap.Int("tournament_id", tournament.ID), zap.Int("round", pairing.Round), zap.Int("board", pairing.Board))
+	if ts.onGameStartCallback != nil {
+		ts.onGameStartCallback(tournament, pairing, gameState)
+	}
+	return nil
+}
+
+func (ts *TournamentService) createGame(red, yellow tournaments.Player) (*models.GameState, error) {
+	player1Info := models.PlayerInfo{
+		ID:       red.ID,
+		Username: red.Username,
+		Color:    models.ColorRed,
+		IsBot:    false,
+	}
+	player2Info := models.PlayerInfo{
+		ID:       yellow.ID,
+		Username: yellow.Username,
+		Color:    models.ColorYellow,
+		IsBot:    false,
+	}
+	return ts.gameService.CreateGame(player1Info, player2Info)
+}
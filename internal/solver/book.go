@@ -0,0 +1,150 @@
+package solver
+
+import (
+	"bufio"
+	"connect4/internal/models"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Book holds precomputed scores for early positions so the solver does not
+// have to search them.
+//
+// The file format is plain text, one position per line:
+//
+//	<moves> <score>
+//
+// where moves is the sequence of columns played from the empty board,
+// numbered 1-7 from the left (e.g. "4453"), or "-" for the empty board, and
+// score is the position's score as returned in Result.Score. Blank lines and
+// lines starting with '#' are ignored. Mirrored positions share an entry, so
+// a book only needs one of each pair.
+type Book struct {
+	scores   map[uint64]int8
+	maxMoves int
+}
+
+func NewBook() *Book {
+	return &Book{scores: make(map[uint64]int8)}
+}
+
+func LoadBook(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open book: %w", err)
+	}
+	defer f.Close()
+	return ReadBook(f)
+}
+
+func ReadBook(r io.Reader) (*Book, error) {
+	b := NewBook()
+	if err := ScanBook(r, b.Add); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ScanBook reads a book file of "<moves> <value>" lines, in the format
+// described on Book, and calls add with each entry. Errors from add are
+// reported with the line number. The bot's move book shares this format,
+// with a column in place of the score.
+func ScanBook(r io.Reader, add func(moves string, value int) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("book line %d: expected \"<moves> <value>\"", lineNum)
+		}
+		value, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("book line %d: invalid value %q", lineNum, fields[1])
+		}
+		if err := add(fields[0], value); err != nil {
+			return fmt.Errorf("book line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read book: %w", err)
+	}
+	return nil
+}
+
+// Add records the score of the position reached by moves.
+func (b *Book) Add(moves string, score int) error {
+	board, err := ParseMoves(moves)
+	if err != nil {
+		return err
+	}
+	if score < minScore || score > maxScore {
+		return fmt.Errorf("score %d out of range", score)
+	}
+	p := fromBitboard(&board)
+	b.scores[p.key()] = int8(score)
+	if p.moves > b.maxMoves {
+		b.maxMoves = p.moves
+	}
+	return nil
+}
+
+func (b *Book) Len() int {
+	return len(b.scores)
+}
+
+func (b *Book) lookup(p *position) (int, bool) {
+	if b == nil || p.moves > b.maxMoves {
+		return 0, false
+	}
+	if score, ok := b.scores[p.key()]; ok {
+		return int(score), true
+	}
+	if score, ok := b.scores[p.mirrorKey()]; ok {
+		return int(score), true
+	}
+	return 0, false
+}
+
+// ParseMoves replays a move sequence in book notation ("-" or columns 1-7)
+// from the empty board. It rejects full columns and moves after a win.
+func ParseMoves(moves string) (models.Bitboard, error) {
+	board := models.NewBitboard()
+	if moves == "-" {
+		return board, nil
+	}
+	for i, ch := range moves {
+		col := int(ch - '1')
+		if col < 0 || col >= width {
+			return board, fmt.Errorf("invalid column %q at move %d", ch, i+1)
+		}
+		player := board.MoveCount()%2 + 1
+		if !board.IsValidMove(col) {
+			return board, fmt.Errorf("column %d is full at move %d", col+1, i+1)
+		}
+		if board.IsWinningMove(col, player) {
+			return board, fmt.Errorf("move %d ends the game", i+1)
+		}
+		board.DropDisc(col, player)
+	}
+	return board, nil
+}
+
+// FormatMoves is the inverse of ParseMoves for 0-based columns.
+func FormatMoves(cols []int) string {
+	if len(cols) == 0 {
+		return "-"
+	}
+	var sb strings.Builder
+	for _, col := range cols {
+		sb.WriteByte(byte('1' + col))
+	}
+	return sb.String()
+}
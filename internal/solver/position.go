@@ -0,0 +1,150 @@
+package solver
+
+import (
+	"connect4/internal/models"
+	"math/bits"
+)
+
+const (
+	width  = 7
+	height = 6
+	cells  = width * height
+)
+
+// The bit layout matches models.Bitboard: bit col*7+h is the cell h rows
+// above the bottom of col, and bit 6 of each column is always empty.
+var (
+	bottomMask = func() uint64 {
+		var m uint64
+		for col := 0; col < width; col++ {
+			m |= 1 << (col * (height + 1))
+		}
+		return m
+	}()
+	boardMask = bottomMask * (1<<height - 1)
+)
+
+func topMask(col int) uint64 {
+	return 1 << (height - 1 + col*(height+1))
+}
+
+func columnMask(col int) uint64 {
+	return (1<<height - 1) << (col * (height + 1))
+}
+
+// position is a Bitboard seen from the side to move: current holds that
+// player's discs and mask holds every disc. Red always moves first, so the
+// side to move follows from the move count.
+type position struct {
+	current uint64
+	mask    uint64
+	moves   int
+}
+
+func fromBitboard(bb *models.Bitboard) position {
+	side := bb.MoveCount()%2 + 1
+	return position{
+		current: bb.Discs(side),
+		mask:    bb.Discs(1) | bb.Discs(2),
+		moves:   bb.MoveCount(),
+	}
+}
+
+// key uniquely identifies the position. It is current+mask, which equals
+// current+(mask+bottom) less the constant bottom row: mask+bottom leaves one
+// bit just above each column's top disc, and adding current cannot carry
+// into it, so no two positions share a key.
+func (p *position) key() uint64 {
+	return p.current + p.mask
+}
+
+// mirrorKey is the key of the position reflected left to right, which has
+// the same score.
+func (p *position) mirrorKey() uint64 {
+	var current, mask uint64
+	for col := 0; col < width; col++ {
+		shift := (width - 1 - 2*col) * (height + 1)
+		if shift >= 0 {
+			current |= (p.current & columnMask(col)) << shift
+			mask |= (p.mask & columnMask(col)) << shift
+		} else {
+			current |= (p.current & columnMask(col)) >> -shift
+			mask |= (p.mask & columnMask(col)) >> -shift
+		}
+	}
+	return current + mask
+}
+
+func (p *position) canPlay(col int) bool {
+	return p.mask&topMask(col) == 0
+}
+
+// play drops a disc on the single bit move, which must come from possible.
+func (p *position) play(move uint64) {
+	p.current ^= p.mask
+	p.mask |= move
+	p.moves++
+}
+
+func (p *position) playColumn(col int) {
+	p.play((p.mask + 1<<(col*(height+1))) & columnMask(col))
+}
+
+func (p *position) possible() uint64 {
+	return (p.mask + bottomMask) & boardMask
+}
+
+func (p *position) canWinNext() bool {
+	return p.winningPosition()&p.possible() != 0
+}
+
+func (p *position) isWinningMove(col int) bool {
+	return p.winningPosition()&p.possible()&columnMask(col) != 0
+}
+
+// possibleNonLosingMoves returns the playable cells that do not hand the
+// opponent an immediate win. It must only be called when the side to move
+// cannot win next.
+func (p *position) possibleNonLosingMoves() uint64 {
+	possible := p.possible()
+	forced := possible & p.opponentWinningPosition()
+	if forced != 0 {
+		if forced&(forced-1) != 0 {
+			// Two threats at once cannot both be blocked.
+			return 0
+		}
+		possible = forced
+	}
+	return possible &^ (p.opponentWinningPosition() >> 1)
+}
+
+func (p *position) winningPosition() uint64 {
+	return computeWinningPosition(p.current, p.mask)
+}
+
+func (p *position) opponentWinningPosition() uint64 {
+	return computeWinningPosition(p.current^p.mask, p.mask)
+}
+
+// moveScore counts the open threats the side to move would have after
+// playing move; more threats are searched first.
+func (p *position) moveScore(move uint64) int {
+	return bits.OnesCount64(computeWinningPosition(p.current|move, p.mask))
+}
+
+// computeWinningPosition returns the empty cells that would complete four in
+// a row for the player owning discs.
+func computeWinningPosition(discs, mask uint64) uint64 {
+	// Vertical.
+	r := (discs << 1) & (discs << 2) & (discs << 3)
+
+	for _, shift := range [3]uint{height, height + 1, height + 2} {
+		p := (discs << shift) & (discs << (2 * shift))
+		r |= p & (discs << (3 * shift))
+		r |= p & (discs >> shift)
+		p = (discs >> shift) & (discs >> (2 * shift))
+		r |= p & (discs << shift)
+		r |= p & (discs >> (3 * shift))
+	}
+	return r & (boardMask ^ mask)
+}
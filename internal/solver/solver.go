@@ -0,0 +1,302 @@
+// Package solver plays Connect 4 perfectly. It scores positions exactly with
+// a negamax search using alpha-beta pruning, a transposition table and an
+// optional opening book.
+package solver
+
+import (
+	"connect4/internal/models"
+	"context"
+	"errors"
+)
+
+const (
+	minScore = -cells/2 + 3
+	maxScore = (cells+1)/2 - 3
+)
+
+// columnOrder tries central columns first; they take part in more lines.
+var columnOrder = [width]int{3, 2, 4, 1, 5, 0, 6}
+
+type Outcome string
+
+const (
+	OutcomeWin  Outcome = "win"
+	OutcomeLoss Outcome = "loss"
+	OutcomeDraw Outcome = "draw"
+)
+
+// Result is the exact value of a position for the side to move.
+//
+// Score is positive for a win, negative for a loss and zero for a draw; the
+// faster the win, the larger the score. Plies is the number of moves, by
+// both sides, until the game ends with perfect play.
+type Result struct {
+	Score   int     `json:"score"`
+	Outcome Outcome `json:"outcome"`
+	Plies   int     `json:"plies"`
+}
+
+// ColumnResult is the Result, for the side to move, of playing Column.
+type ColumnResult struct {
+	Column int `json:"column"`
+	Result
+}
+
+// Solver is safe for concurrent use. Each search takes its own
+// transposition table from a pool, so searches run side by side.
+type Solver struct {
+	book *Book
+}
+
+// New returns a solver. book may be nil.
+func New(book *Book) *Solver {
+	return &Solver{book: book}
+}
+
+// search is the state of one Solve or Analyze call.
+type search struct {
+	table   *table
+	book    *Book
+	ctx     context.Context
+	nodes   uint64
+	stopped bool
+}
+
+func (s *Solver) newSearch(ctx context.Context) *search {
+	return &search{table: acquireTable(), book: s.book, ctx: ctx}
+}
+
+func (se *search) release() {
+	releaseTable(se.table)
+	se.table = nil
+}
+
+// Solve scores the position, searching until it is done or ctx is
+// cancelled, in which case ctx's error is returned. Positions that are
+// already won or full cannot be solved.
+func (s *Solver) Solve(ctx context.Context, board models.Bitboard) (Result, error) {
+	if err := checkPlayable(&board); err != nil {
+		return Result{}, err
+	}
+	se := s.newSearch(ctx)
+	defer se.release()
+
+	p := fromBitboard(&board)
+	score := se.solve(&p)
+	if se.stopped {
+		return Result{}, ctx.Err()
+	}
+	return resultFor(score, p.moves), nil
+}
+
+// Analyze scores every playable column, in column order.
+func (s *Solver) Analyze(ctx context.Context, board models.Bitboard) ([]ColumnResult, error) {
+	if err := checkPlayable(&board); err != nil {
+		return nil, err
+	}
+	se := s.newSearch(ctx)
+	defer se.release()
+
+	p := fromBitboard(&board)
+	var results []ColumnResult
+	for col := 0; col < width; col++ {
+		if !p.canPlay(col) {
+			continue
+		}
+		if p.isWinningMove(col) {
+			score := (cells + 1 - p.moves) / 2
+			results = append(results, ColumnResult{Column: col, Result: resultFor(score, p.moves)})
+			continue
+		}
+		next := p
+		next.playColumn(col)
+		var score int
+		if next.moves == cells {
+			score = 0
+		} else {
+			score = -se.solve(&next)
+		}
+		if se.stopped {
+			return nil, ctx.Err()
+		}
+		results = append(results, ColumnResult{Column: col, Result: resultFor(score, p.moves)})
+	}
+	return results, nil
+}
+
+// BestMove returns the highest-scoring column, preferring central columns
+// among equals.
+func (s *Solver) BestMove(ctx context.Context, board models.Bitboard) (int, Result, error) {
+	results, err := s.Analyze(ctx, board)
+	if err != nil {
+		return -1, Result{}, err
+	}
+	best := -1
+	var bestResult Result
+	for _, col := range columnOrder {
+		for _, r := range results {
+			if r.Column == col && (best == -1 || r.Score > bestResult.Score) {
+				best, bestResult = col, r.Result
+			}
+		}
+	}
+	return best, bestResult, nil
+}
+
+func checkPlayable(board *models.Bitboard) error {
+	if board.IsFull() || board.CheckWin(1) || board.CheckWin(2) {
+		return errors.New("solver: game is already over")
+	}
+	return nil
+}
+
+// resultFor converts a score for a position with moves discs played.
+func resultFor(score, moves int) Result {
+	switch {
+	case score > 0:
+		return Result{Score: score, Outcome: OutcomeWin, Plies: pliesToWin(score, moves, moves)}
+	case score < 0:
+		return Result{Score: score, Outcome: OutcomeLoss, Plies: pliesToWin(-score, moves, moves+1)}
+	default:
+		return Result{Score: 0, Outcome: OutcomeDraw, Plies: cells - moves}
+	}
+}
+
+// pliesToWin inverts the scoring rule score = (43 - n) / 2, where n is the
+// number of discs on the board before the winning move. The winner moves
+// when n has the parity of winnerMoves.
+func pliesToWin(score, moves, winnerMoves int) int {
+	n := cells + 1 - 2*score
+	if n%2 != winnerMoves%2 {
+		n--
+	}
+	return n - moves + 1
+}
+
+// solve narrows the score window with null-window searches until the exact
+// score is known.
+func (s *search) solve(p *position) int {
+	if p.canWinNext() {
+		return (cells + 1 - p.moves) / 2
+	}
+	lo := -(cells - p.moves) / 2
+	hi := (cells + 1 - p.moves) / 2
+	for lo < hi {
+		med := lo + (hi-lo)/2
+		if med <= 0 && lo/2 < med {
+			med = lo / 2
+		} else if med >= 0 && hi/2 > med {
+			med = hi / 2
+		}
+		r := s.negamax(p, med, med+1)
+		if s.stopped {
+			return 0
+		}
+		if r <= med {
+			hi = r
+		} else {
+			lo = r
+		}
+	}
+	return lo
+}
+
+func (s *search) expired() bool {
+	if s.stopped {
+		return true
+	}
+	s.nodes++
+	if s.nodes%4096 == 0 && s.ctx.Err() != nil {
+		s.stopped = true
+	}
+	return s.stopped
+}
+
+// negamax returns the score of p, which must not allow the side to move an
+// immediate win. Scores outside [alpha, beta] are only bounds.
+func (s *search) negamax(p *position, alpha, beta int) int {
+	if s.expired() {
+		return 0
+	}
+
+	next := p.possibleNonLosingMoves()
+	if next == 0 {
+		return -(cells - p.moves) / 2
+	}
+	if p.moves >= cells-2 {
+		return 0
+	}
+	if score, ok := s.book.lookup(p); ok {
+		return score
+	}
+
+	lo := -(cells - 2 - p.moves) / 2
+	if alpha < lo {
+		alpha = lo
+		if alpha >= beta {
+			return alpha
+		}
+	}
+	hi := (cells - 1 - p.moves) / 2
+	if v := s.table.get(p.key()); v != 0 {
+		hi = int(v) + minScore - 1
+	}
+	if beta > hi {
+		beta = hi
+		if alpha >= beta {
+			return beta
+		}
+	}
+
+	var moves moveSorter
+	for i := width - 1; i >= 0; i-- {
+		if move := next & columnMask(columnOrder[i]); move != 0 {
+			moves.add(move, p.moveScore(move))
+		}
+	}
+
+	for move := moves.next(); move != 0; move = moves.next() {
+		child := *p
+		child.play(move)
+		score := -s.negamax(&child, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	s.table.put(p.key(), uint8(alpha-minScore+1))
+	return alpha
+}
+
+// moveSorter is an insertion sort over at most seven moves. Moves with equal
+// scores come out in reverse insertion order.
+type moveSorter struct {
+	size   int
+	moves  [width]uint64
+	scores [width]int
+}
+
+func (m *moveSorter) add(move uint64, score int) {
+	pos := m.size
+	m.size++
+	for ; pos > 0 && m.scores[pos-1] > score; pos-- {
+		m.moves[pos] = m.moves[pos-1]
+		m.scores[pos] = m.scores[pos-1]
+	}
+	m.moves[pos] = move
+	m.scores[pos] = score
+}
+
+func (m *moveSorter) next() uint64 {
+	if m.size == 0 {
+		return 0
+	}
+	m.size--
+	return m.moves[m.size]
+}
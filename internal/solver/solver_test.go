@@ -0,0 +1,283 @@
+package solver
+
+import (
+	"connect4/internal/models"
+	"context"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, moves string) models.Bitboard {
+	t.Helper()
+	board, err := ParseMoves(moves)
+	if err != nil {
+		t.Fatalf("ParseMoves(%q): %v", moves, err)
+	}
+	return board
+}
+
+// referenceScore scores board by plain alpha-beta over models.Bitboard,
+// with none of the solver's shortcuts. It is only fast enough for nearly
+// full boards.
+func referenceScore(board models.Bitboard, alpha, beta int) int {
+	moves := board.MoveCount()
+	if moves == cells {
+		return 0
+	}
+	player := moves%2 + 1
+	for col := 0; col < width; col++ {
+		if board.IsValidMove(col) && board.IsWinningMove(col, player) {
+			return (cells + 1 - moves) / 2
+		}
+	}
+	for col := 0; col < width; col++ {
+		if !board.IsValidMove(col) {
+			continue
+		}
+		child := board
+		child.DropDisc(col, player)
+		if score := -referenceScore(child, -beta, -alpha); score > alpha {
+			alpha = score
+			if alpha >= beta {
+				break
+			}
+		}
+	}
+	return alpha
+}
+
+// randomPosition plays random moves that do not end the game until discs
+// are on the board.
+func randomPosition(rng *rand.Rand, discs int) models.Bitboard {
+	for {
+		board := models.NewBitboard()
+		for board.MoveCount() < discs {
+			player := board.MoveCount()%2 + 1
+			var cols []int
+			for col := 0; col < width; col++ {
+				if board.IsValidMove(col) && !board.IsWinningMove(col, player) {
+					cols = append(cols, col)
+				}
+			}
+			if len(cols) == 0 {
+				break
+			}
+			board.DropDisc(cols[rng.IntN(len(cols))], player)
+		}
+		if board.MoveCount() == discs {
+			return board
+		}
+	}
+}
+
+func TestSolveKnownPositions(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves string
+		want  Result
+	}{
+		// Red has 1-3 on the bottom row and wins with its fourth disc.
+		{"immediate win", "112233", Result{Score: 18, Outcome: OutcomeWin, Plies: 1}},
+		// Red has 3-5 on the bottom row with both ends open; yellow can
+		// block only one.
+		{"forced loss", "33445", Result{Score: -18, Outcome: OutcomeLoss, Plies: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(nil).Solve(context.Background(), mustParse(t, tt.moves))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Solve(%s) = %+v, want %+v", tt.moves, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolveMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 7))
+	s := New(nil)
+	for i := 0; i < 40; i++ {
+		board := randomPosition(rng, 22)
+		want := referenceScore(board, -cells, cells)
+		got, err := s.Solve(context.Background(), board)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Score != want {
+			t.Errorf("position %d: Solve score %d, reference %d", i, got.Score, want)
+		}
+
+		results, err := s.Analyze(context.Background(), board)
+		if err != nil {
+			t.Fatal(err)
+		}
+		best := -cells
+		for _, r := range results {
+			child := board
+			child.DropDisc(r.Column, board.MoveCount()%2+1)
+			want := (cells + 1 - board.MoveCount()) / 2
+			if !child.CheckWin(board.MoveCount()%2 + 1) {
+				want = -referenceScore(child, -cells, cells)
+			}
+			if r.Score != want {
+				t.Errorf("position %d, column %d: Analyze score %d, reference %d", i, r.Column, r.Score, want)
+			}
+			best = max(best, r.Score)
+		}
+		if best != got.Score {
+			t.Errorf("position %d: best column scores %d, Solve %d", i, best, got.Score)
+		}
+	}
+}
+
+func TestSolveRejectsFinishedGames(t *testing.T) {
+	board := mustParse(t, "112233")
+	board.DropDisc(3, 1)
+	if _, err := New(nil).Solve(context.Background(), board); err == nil {
+		t.Error("Solve of a won position succeeded")
+	}
+}
+
+func TestSolveUsesBook(t *testing.T) {
+	// The values of the empty board and of the centre opening are known
+	// (red wins with its last disc), but take far too long to search here.
+	book, err := ReadBook(strings.NewReader("# known values\n- 1\n4 -1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		moves string
+		want  Result
+	}{
+		{"-", Result{Score: 1, Outcome: OutcomeWin, Plies: 41}},
+		{"4", Result{Score: -1, Outcome: OutcomeLoss, Plies: 40}},
+	}
+	s := New(book)
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		got, err := s.Solve(ctx, mustParse(t, tt.moves))
+		cancel()
+		if err != nil {
+			t.Fatalf("Solve(%s): %v", tt.moves, err)
+		}
+		if got != tt.want {
+			t.Errorf("Solve(%s) = %+v, want %+v", tt.moves, got, tt.want)
+		}
+	}
+}
+
+func TestBookAnswersForMirror(t *testing.T) {
+	book, err := ReadBook(strings.NewReader("12 -3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, moves := range []string{"12", "76"} {
+		board := mustParse(t, moves)
+		p := fromBitboard(&board)
+		if score, ok := book.lookup(&p); !ok || score != -3 {
+			t.Errorf("lookup(%s) = %d, %v, want -3, true", moves, score, ok)
+		}
+	}
+	board := mustParse(t, "21")
+	p := fromBitboard(&board)
+	if _, ok := book.lookup(&p); ok {
+		t.Error("lookup(21) found an entry for a different position")
+	}
+}
+
+func TestReadBookErrors(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"missing score", "44\n", "book line 1"},
+		{"bad score", "- x\n", "invalid value"},
+		{"bad column", "48 0\n", "invalid column"},
+		{"score out of range", "# ok\n- 40\n", "book line 2: score 40 out of range"},
+		{"move after a win", "1212121 0\n", "ends the game"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBook(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ReadBook(%q) error = %v, want it to mention %q", tt.input, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	// Positions up to four moves share a key only when they are the same
+	// position reached in a different order, and the mirror key is the key
+	// of the mirrored board.
+	type seenPosition struct {
+		moves string
+		board models.Bitboard
+	}
+	seen := make(map[uint64]seenPosition)
+	var walk func(moves string, board models.Bitboard)
+	walk = func(moves string, board models.Bitboard) {
+		p := fromBitboard(&board)
+		if other, ok := seen[p.key()]; ok && other.board != board {
+			t.Fatalf("positions %q and %q share key %#x", other.moves, moves, p.key())
+		}
+		seen[p.key()] = seenPosition{moves, board}
+
+		mirror := board.Mirror()
+		m := fromBitboard(&mirror)
+		if p.mirrorKey() != m.key() || m.mirrorKey() != p.key() {
+			t.Fatalf("position %q: mirror keys do not round-trip", moves)
+		}
+
+		if len(moves) == 4 {
+			return
+		}
+		player := board.MoveCount()%2 + 1
+		for col := 0; col < width; col++ {
+			child := board
+			child.DropDisc(col, player)
+			walk(moves+string(rune('1'+col)), child)
+		}
+	}
+	walk("", models.NewBitboard())
+}
+
+func TestConcurrentSearchesDoNotWait(t *testing.T) {
+	s := New(nil)
+	long, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := make(chan struct{})
+	go func() {
+		close(started)
+		s.Solve(long, models.NewBitboard())
+	}()
+	<-started
+	time.Sleep(50 * time.Millisecond)
+
+	// The empty board takes minutes; a second search must not wait for it.
+	type solved struct {
+		result Result
+		err    error
+	}
+	done := make(chan solved, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		result, err := s.Solve(ctx, mustParse(t, "112233"))
+		done <- solved{result, err}
+	}()
+	select {
+	case got := <-done:
+		if got.err != nil {
+			t.Fatalf("Solve while another search runs: %v", got.err)
+		}
+		if got.result.Score != 18 {
+			t.Errorf("Solve score = %d, want 18", got.result.Score)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Solve waited for another search")
+	}
+}
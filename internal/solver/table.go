@@ -0,0 +1,51 @@
+package solver
+
+import "sync"
+
+// tableSize is prime and larger than 2^17, so the slot index (key mod
+// tableSize) together with the low 32 bits of a 49-bit key identify the key
+// exactly.
+const tableSize = 1048573
+
+// table is an always-replace transposition table storing upper bounds. A
+// stored value of zero means the slot is empty. Bounds hold for a position
+// whatever search found them, so pooled tables are reused without clearing.
+type table struct {
+	keys   []uint32
+	values []uint8
+}
+
+func newTable() *table {
+	return &table{
+		keys:   make([]uint32, tableSize),
+		values: make([]uint8, tableSize),
+	}
+}
+
+var tablePool = sync.Pool{
+	New: func() interface{} {
+		return newTable()
+	},
+}
+
+func acquireTable() *table {
+	return tablePool.Get().(*table)
+}
+
+func releaseTable(t *table) {
+	tablePool.Put(t)
+}
+
+func (t *table) put(key uint64, value uint8) {
+	i := key % tableSize
+	t.keys[i] = uint32(key)
+	t.values[i] = value
+}
+
+func (t *table) get(key uint64) uint8 {
+	i := key % tableSize
+	if t.keys[i] != uint32(key) {
+		return 0
+	}
+	return t.values[i]
+}
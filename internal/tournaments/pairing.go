@@ -0,0 +1,239 @@
+package tournaments
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var errRoundUnfinished = errors.New("previous round is not finished")
+
+// Pair returns the pairings for round (counting from 1), given every
+// registered player and all pairings of earlier rounds. Boards are numbered
+// from 1.
+func Pair(format Format, round int, players []Player, history []Pairing) ([]Pairing, error) {
+	if len(players) < 2 {
+		return nil, ErrNotEnoughPlayers
+	}
+	seeded := make([]Player, len(players))
+	copy(seeded, players)
+	sort.Slice(seeded, func(i, j int) bool { return seeded[i].Seed < seeded[j].Seed })
+
+	var pairs [][2]int
+	var err error
+	switch format {
+	case FormatRoundRobin:
+		pairs = pairRoundRobin(round, seeded)
+	case FormatKnockout:
+		pairs, err = pairKnockout(round, seeded, history)
+	case FormatSwiss:
+		pairs = pairSwiss(round, seeded, history)
+	default:
+		err = fmt.Errorf("unknown tournament format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Knockout boards follow the bracket; elsewhere a bye goes last.
+	if format != FormatKnockout {
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i][1] != 0 && pairs[j][1] == 0 })
+	}
+	pairings := make([]Pairing, len(pairs))
+	for i, p := range pairs {
+		pairings[i] = Pairing{Round: round, Board: i + 1, Player1: p[0], Player2: p[1], Result: ResultPending}
+		if p[1] == 0 {
+			pairings[i].Result = ResultBye
+		}
+	}
+	return pairings, nil
+}
+
+// pairRoundRobin uses the circle method: the top seed stays put while the
+// others rotate one place each round. With an odd number of players the
+// empty seat is the bye.
+func pairRoundRobin(round int, seeded []Player) [][2]int {
+	ids := make([]int, 0, len(seeded)+1)
+	for _, p := range seeded {
+		ids = append(ids, p.ID)
+	}
+	if len(ids)%2 == 1 {
+		ids = append(ids, 0)
+	}
+	n := len(ids)
+	k := round - 1
+
+	seats := make([]int, n)
+	seats[0] = ids[0]
+	for i := 1; i < n; i++ {
+		seats[i] = ids[1+(i-1+k)%(n-1)]
+	}
+
+	pairs := make([][2]int, 0, n/2)
+	for i := 0; i < n/2; i++ {
+		a, b := seats[i], seats[n-1-i]
+		// Alternate colours so each player gets red about half the time.
+		if (i+k)%2 == 1 {
+			a, b = b, a
+		}
+		if a == 0 {
+			a, b = b, a
+		}
+		pairs = append(pairs, [2]int{a, b})
+	}
+	return pairs
+}
+
+// pairKnockout seeds the first round into a bracket so the top seeds can
+// only meet late, with byes for the top seeds when the field is not a power
+// of two. Later rounds pair the winners of neighbouring boards.
+func pairKnockout(round int, seeded []Player, history []Pairing) ([][2]int, error) {
+	if round == 1 {
+		size := 1 << log2Ceil(len(seeded))
+		order := bracketOrder(size)
+		pairs := make([][2]int, 0, size/2)
+		for i := 0; i < size; i += 2 {
+			a, b := order[i], order[i+1]
+			pair := [2]int{seeded[a-1].ID, 0}
+			if b <= len(seeded) {
+				pair[1] = seeded[b-1].ID
+			}
+			pairs = append(pairs, pair)
+		}
+		return pairs, nil
+	}
+
+	var previous []Pairing
+	for _, p := range history {
+		if p.Round == round-1 {
+			previous = append(previous, p)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool { return previous[i].Board < previous[j].Board })
+
+	seeds := make(map[int]int, len(seeded))
+	for _, p := range seeded {
+		seeds[p.ID] = p.Seed
+	}
+	winners := make([]int, 0, len(previous))
+	for _, p := range previous {
+		switch p.Result {
+		case ResultPlayer1, ResultBye:
+			winners = append(winners, p.Player1)
+		case ResultPlayer2:
+			winners = append(winners, p.Player2)
+		default:
+			return nil, errRoundUnfinished
+		}
+	}
+
+	pairs := make([][2]int, 0, len(winners)/2)
+	for i := 0; i+1 < len(winners); i += 2 {
+		a, b := winners[i], winners[i+1]
+		if seeds[b] < seeds[a] {
+			a, b = b, a
+		}
+		pairs = append(pairs, [2]int{a, b})
+	}
+	return pairs, nil
+}
+
+// bracketOrder lists seeds 1..size in first-round bracket order, e.g.
+// 1 8 4 5 2 7 3 6 for eight, so that each pair of neighbours meets first
+// and seeds 1 and 2 can only meet in the final.
+func bracketOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, s := range order {
+			next = append(next, s, 2*len(order)+1-s)
+		}
+		order = next
+	}
+	return order
+}
+
+// pairSwiss pairs players Monrad-style: in rank order, each with the next
+// player they have not met, so scores stay as close as possible. With an
+// odd number of players the lowest-ranked player without a bye sits out. If
+// no pairing avoids every rematch, rematches are allowed.
+func pairSwiss(round int, seeded []Player, history []Pairing) [][2]int {
+	points := scores(history)
+	played := make(map[[2]int]bool)
+	hadBye := make(map[int]bool)
+	balance := make(map[int]int) // red games minus yellow games
+	for _, p := range history {
+		if p.IsBye() {
+			hadBye[p.Player1] = true
+			continue
+		}
+		played[pairKey(p.Player1, p.Player2)] = true
+		balance[p.Player1]++
+		balance[p.Player2]--
+	}
+
+	ranked := make([]Player, len(seeded))
+	copy(ranked, seeded)
+	sort.SliceStable(ranked, func(i, j int) bool { return points[ranked[i].ID] > points[ranked[j].ID] })
+	order := make([]int, len(ranked))
+	for i, p := range ranked {
+		order[i] = p.ID
+	}
+
+	var pairs [][2]int
+	if len(order)%2 == 1 {
+		bye := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if !hadBye[order[i]] {
+				bye = i
+				break
+			}
+		}
+		pairs = append(pairs, [2]int{order[bye], 0})
+		order = append(order[:bye], order[bye+1:]...)
+	}
+
+	matched, ok := matchSwiss(order, played)
+	if !ok {
+		matched, _ = matchSwiss(order, nil)
+	}
+	for _, m := range matched {
+		a, b := m[0], m[1]
+		// The player who has had red less often gets it; on a tie the
+		// higher-ranked player alternates between red and yellow by round.
+		if balance[b] < balance[a] || (balance[a] == balance[b] && round%2 == 0) {
+			a, b = b, a
+		}
+		pairs = append(pairs, [2]int{a, b})
+	}
+	return pairs
+}
+
+// matchSwiss pairs each player, in rank order, with the highest-ranked
+// opponent they have not played, backtracking when that leaves the rest
+// unpairable.
+func matchSwiss(order []int, played map[[2]int]bool) ([][2]int, bool) {
+	if len(order) == 0 {
+		return nil, true
+	}
+	first := order[0]
+	for j := 1; j < len(order); j++ {
+		if played[pairKey(first, order[j])] {
+			continue
+		}
+		rest := make([]int, 0, len(order)-2)
+		rest = append(rest, order[1:j]...)
+		rest = append(rest, order[j+1:]...)
+		if matched, ok := matchSwiss(rest, played); ok {
+			return append([][2]int{{first, order[j]}}, matched...), true
+		}
+	}
+	return nil, false
+}
+
+func pairKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
@@ -0,0 +1,204 @@
+package tournaments
+
+import (
+	"reflect"
+	"testing"
+)
+
+// seeded returns n players whose IDs are ten times their seeds.
+func seeded(n int) []Player {
+	players := make([]Player, n)
+	for i := range players {
+		players[i] = Player{ID: 10 * (i + 1), Seed: i + 1}
+	}
+	return players
+}
+
+// boards reduces pairings to [red, yellow] pairs, yellow zero for a bye.
+func boards(pairings []Pairing) [][2]int {
+	pairs := make([][2]int, len(pairings))
+	for i, p := range pairings {
+		pairs[i] = [2]int{p.Player1, p.Player2}
+	}
+	return pairs
+}
+
+// play records a result for every pending pairing, red winning unless
+// yellowWins says otherwise.
+func play(pairings []Pairing, yellowWins func(p Pairing) bool) []Pairing {
+	for i := range pairings {
+		if pairings[i].Result != ResultPending {
+			continue
+		}
+		pairings[i].Result = ResultPlayer1
+		if yellowWins != nil && yellowWins(pairings[i]) {
+			pairings[i].Result = ResultPlayer2
+		}
+	}
+	return pairings
+}
+
+func TestBracketOrder(t *testing.T) {
+	tests := []struct {
+		size int
+		want []int
+	}{
+		{1, []int{1}},
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	}
+	for _, tt := range tests {
+		if got := bracketOrder(tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("bracketOrder(%d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestPairKnockout(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		want    [][2]int
+	}{
+		{"full bracket", 4, [][2]int{{10, 40}, {20, 30}}},
+		{"top seeds get byes", 6, [][2]int{{10, 0}, {40, 50}, {20, 0}, {30, 60}}},
+		{"one bye", 7, [][2]int{{10, 0}, {40, 50}, {20, 70}, {30, 60}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairings, err := Pair(FormatKnockout, 1, seeded(tt.players), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := boards(pairings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("round 1 = %v, want %v", got, tt.want)
+			}
+			for _, p := range pairings {
+				if p.IsBye() != (p.Result == ResultBye) {
+					t.Errorf("board %d: bye %v with result %s", p.Board, p.IsBye(), p.Result)
+				}
+			}
+		})
+	}
+}
+
+func TestPairKnockoutLaterRounds(t *testing.T) {
+	players := seeded(6)
+	round1, err := Pair(FormatKnockout, 1, players, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seed 5 beats seed 4; seed 3 beats seed 6.
+	history := play(round1, func(p Pairing) bool { return p.Player1 == 40 })
+
+	round2, err := Pair(FormatKnockout, 2, players, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neighbouring boards meet, the better seed taking red.
+	if got, want := boards(round2), [][2]int{{10, 50}, {20, 30}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("round 2 = %v, want %v", got, want)
+	}
+
+	if _, err := Pair(FormatKnockout, 3, players, append(history, round2...)); err != errRoundUnfinished {
+		t.Errorf("pairing after an unfinished round: err = %v, want %v", err, errRoundUnfinished)
+	}
+}
+
+func TestPairRoundRobin(t *testing.T) {
+	for _, n := range []int{4, 5, 6} {
+		players := seeded(n)
+		rounds := RoundsFor(FormatRoundRobin, n, 0)
+		met := make(map[[2]int]int)
+		byes := make(map[int]int)
+		for round := 1; round <= rounds; round++ {
+			pairings, err := Pair(FormatRoundRobin, round, players, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range pairings {
+				if p.IsBye() {
+					byes[p.Player1]++
+					continue
+				}
+				met[pairKey(p.Player1, p.Player2)]++
+			}
+		}
+		if want := n * (n - 1) / 2; len(met) != want {
+			t.Errorf("%d players: %d distinct games, want %d", n, len(met), want)
+		}
+		for pair, count := range met {
+			if count != 1 {
+				t.Errorf("%d players: %v met %d times", n, pair, count)
+			}
+		}
+		for _, p := range players {
+			if want := n % 2; byes[p.ID] != want {
+				t.Errorf("%d players: player %d had %d byes, want %d", n, p.ID, byes[p.ID], want)
+			}
+		}
+	}
+}
+
+func TestPairSwissAvoidsRematches(t *testing.T) {
+	tests := []struct {
+		players, rounds int
+	}{
+		{4, 3},
+		{6, 3},
+		{8, 4},
+	}
+	for _, tt := range tests {
+		players := seeded(tt.players)
+		var history []Pairing
+		met := make(map[[2]int]bool)
+		for round := 1; round <= tt.rounds; round++ {
+			pairings, err := Pair(FormatSwiss, round, players, history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range pairings {
+				key := pairKey(p.Player1, p.Player2)
+				if met[key] {
+					t.Errorf("%d players, round %d: %v is a rematch", tt.players, round, key)
+				}
+				met[key] = true
+			}
+			// The lower seed wins, so the score groups do not simply
+			// follow the seeds.
+			history = append(history, play(pairings, func(p Pairing) bool { return p.Player2 > p.Player1 })...)
+		}
+	}
+}
+
+func TestPairSwissRotatesByes(t *testing.T) {
+	players := seeded(5)
+	var history []Pairing
+	byes := make(map[int]int)
+	for round := 1; round <= 5; round++ {
+		pairings, err := Pair(FormatSwiss, round, players, history)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, p := range pairings {
+			if p.IsBye() {
+				got = append(got, p.Player1)
+				byes[p.Player1]++
+			}
+		}
+		if len(got) != 1 {
+			t.Fatalf("round %d: byes %v, want exactly one", round, got)
+		}
+		if last := pairings[len(pairings)-1]; !last.IsBye() {
+			t.Errorf("round %d: bye is not on the last board", round)
+		}
+		history = append(history, play(pairings, nil)...)
+	}
+	for _, p := range players {
+		if byes[p.ID] != 1 {
+			t.Errorf("player %d had %d byes, want 1", p.ID, byes[p.ID])
+		}
+	}
+}
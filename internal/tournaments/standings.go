@@ -0,0 +1,97 @@
+package tournaments
+
+import "sort"
+
+type Standing struct {
+	Rank            int     `json:"rank"`
+	PlayerID        int     `json:"player_id"`
+	Username        string  `json:"username"`
+	Seed            int     `json:"seed"`
+	Points          float64 `json:"points"`
+	Buchholz        float64 `json:"buchholz"`
+	SonnebornBerger float64 `json:"sonneborn_berger"`
+	Wins            int     `json:"wins"`
+	Draws           int     `json:"draws"`
+	Losses          int     `json:"losses"`
+	Byes            int     `json:"byes"`
+}
+
+// Standings ranks players by points, then Buchholz (the sum of their
+// opponents' points), then Sonneborn-Berger (the points of opponents they
+// beat plus half those of opponents they drew), then seed. A bye scores a
+// point but adds nothing to either tie-break.
+func Standings(players []Player, pairings []Pairing) []Standing {
+	points := scores(pairings)
+	byID := make(map[int]*Standing, len(players))
+	standings := make([]Standing, len(players))
+	for i, p := range players {
+		standings[i] = Standing{PlayerID: p.ID, Username: p.Username, Seed: p.Seed, Points: points[p.ID]}
+		byID[p.ID] = &standings[i]
+	}
+
+	for _, p := range pairings {
+		s1, s2 := byID[p.Player1], byID[p.Player2]
+		switch p.Result {
+		case ResultBye:
+			if s1 != nil {
+				s1.Byes++
+			}
+		case ResultPlayer1, ResultPlayer2, ResultDraw:
+			if s1 == nil || s2 == nil {
+				continue
+			}
+			s1.Buchholz += points[p.Player2]
+			s2.Buchholz += points[p.Player1]
+			switch p.Result {
+			case ResultPlayer1:
+				s1.Wins++
+				s2.Losses++
+				s1.SonnebornBerger += points[p.Player2]
+			case ResultPlayer2:
+				s2.Wins++
+				s1.Losses++
+				s2.SonnebornBerger += points[p.Player1]
+			default:
+				s1.Draws++
+				s2.Draws++
+				s1.SonnebornBerger += points[p.Player2] / 2
+				s2.SonnebornBerger += points[p.Player1] / 2
+			}
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		if a.SonnebornBerger != b.SonnebornBerger {
+			return a.SonnebornBerger > b.SonnebornBerger
+		}
+		return a.Seed < b.Seed
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings
+}
+
+// scores totals each player's points over the finished pairings.
+func scores(pairings []Pairing) map[int]float64 {
+	points := make(map[int]float64)
+	for _, p := range pairings {
+		switch p.Result {
+		case ResultPlayer1, ResultBye:
+			points[p.Player1]++
+		case ResultPlayer2:
+			points[p.Player2]++
+		case ResultDraw:
+			points[p.Player1] += 0.5
+			points[p.Player2] += 0.5
+		}
+	}
+	return points
+}
@@ -0,0 +1,138 @@
+// Package tournaments holds the rules of Swiss, round-robin and knockout
+// tournaments: how many rounds they run, who plays whom each round and how
+// the standings are ranked. Persistence and game creation live in the
+// database and services packages.
+package tournaments
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Format string
+
+const (
+	FormatSwiss      Format = "swiss"
+	FormatRoundRobin Format = "round-robin"
+	FormatKnockout   Format = "knockout"
+)
+
+type Status string
+
+const (
+	StatusRegistering Status = "registering"
+	StatusRunning     Status = "running"
+	StatusFinished    Status = "finished"
+)
+
+// Result is the outcome of a pairing. A bye counts as a win for Player1.
+type Result string
+
+const (
+	ResultPending Result = "pending"
+	ResultPlayer1 Result = "player1"
+	ResultPlayer2 Result = "player2"
+	ResultDraw    Result = "draw"
+	ResultBye     Result = "bye"
+)
+
+var (
+	ErrNotFound         = errors.New("tournament not found")
+	ErrNotRegistering   = errors.New("tournament is not open for registration")
+	ErrAlreadyJoined    = errors.New("player already registered")
+	ErrNotEnoughPlayers = errors.New("tournament needs at least two players")
+	ErrNotCreator       = errors.New("only the creator can start the tournament")
+)
+
+type Tournament struct {
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	Format       Format     `json:"format"`
+	Status       Status     `json:"status"`
+	Rounds       int        `json:"rounds"`
+	CurrentRound int        `json:"current_round"`
+	CreatedBy    string     `json:"created_by"`
+	PlayerCount  int        `json:"player_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// Player is a registered entrant. Seed is fixed when the tournament starts,
+// 1 being the highest rated.
+type Player struct {
+	ID       int     `json:"id"`
+	Username string  `json:"username"`
+	Rating   float64 `json:"rating"`
+	Seed     int     `json:"seed"`
+}
+
+// Pairing is one board of one round. Player1 plays red; Player2 is zero for
+// a bye.
+type Pairing struct {
+	ID           int        `json:"id"`
+	TournamentID int        `json:"tournament_id"`
+	Round        int        `json:"round"`
+	Board        int        `json:"board"`
+	Player1      int        `json:"player1_id"`
+	Player2      int        `json:"player2_id,omitempty"`
+	GameID       *uuid.UUID `json:"game_id,omitempty"`
+	Result       Result     `json:"result"`
+}
+
+func (p Pairing) IsBye() bool {
+	return p.Player2 == 0
+}
+
+// Summary is a tournament with everything needed to display it.
+type Summary struct {
+	Tournament *Tournament `json:"tournament"`
+	Players    []Player    `json:"players"`
+	Pairings   []Pairing   `json:"pairings"`
+	Standings  []Standing  `json:"standings"`
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatSwiss, FormatRoundRobin, FormatKnockout:
+		return f, nil
+	case "":
+		return FormatSwiss, nil
+	}
+	return "", fmt.Errorf("unknown tournament format %q", s)
+}
+
+// RoundsFor returns how many rounds a tournament of n players runs.
+// Round-robin and knockout are fixed by n; Swiss uses requested, defaulting
+// to enough rounds to separate a single winner and capped at n-1 so no one
+// has to meet an opponent twice.
+func RoundsFor(format Format, n, requested int) int {
+	switch format {
+	case FormatRoundRobin:
+		if n%2 == 0 {
+			return n - 1
+		}
+		return n
+	case FormatKnockout:
+		return log2Ceil(n)
+	}
+	rounds := requested
+	if rounds <= 0 {
+		rounds = log2Ceil(n)
+	}
+	if rounds > n-1 {
+		rounds = n - 1
+	}
+	return rounds
+}
+
+func log2Ceil(n int) int {
+	rounds := 0
+	for size := 1; size < n; size *= 2 {
+		rounds++
+	}
+	return rounds
+}
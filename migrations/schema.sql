@@ -1,6 +1,12 @@
 
 
 
+DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS tournament_pairings CASCADE;
+DROP TABLE IF EXISTS tournament_players CASCADE;
+DROP TABLE IF EXISTS tournaments CASCADE;
+DROP TABLE IF EXISTS rating_history CASCADE;
+DROP TABLE IF EXISTS chat_messages CASCADE;
 DROP TABLE IF EXISTS game_moves CASCADE;
 DROP TABLE IF EXISTS game_analytics CASCADE;
 DROP TABLE IF EXISTS games CASCADE;
@@ -13,6 +19,13 @@ CREATE TABLE players (
     username VARCHAR(50) UNIQUE NOT NULL,
     games_played INT DEFAULT 0,
     games_won INT DEFAULT 0,
+    rating DOUBLE PRECISION NOT NULL DEFAULT 1500,
+    rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350,
+    rating_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06,
+    -- Registered players have a bcrypt password hash; guests and players
+    -- from before accounts existed do not.
+    password_hash VARCHAR(100),
+    is_guest BOOLEAN NOT NULL DEFAULT FALSE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -24,7 +37,8 @@ CREATE TABLE games (
     player2_id INT REFERENCES players(id),
     player2_is_bot BOOLEAN DEFAULT FALSE,
     winner_id INT REFERENCES players(id),
-    status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'completed', 'forfeited', 'draw')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'completed', 'forfeited', 'draw', 'timeout', 'resigned')),
+    time_control VARCHAR(20) NOT NULL DEFAULT '',
     duration_seconds INT,
     total_moves INT DEFAULT 0,
     started_at TIMESTAMP NOT NULL,
@@ -37,9 +51,79 @@ CREATE TABLE game_moves (
     id SERIAL PRIMARY KEY,
     game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
     player_id INT NOT NULL REFERENCES players(id),
-    column_index INT NOT NULL CHECK (column_index >= 0 AND column_index <= 6),
-    row_index INT NOT NULL CHECK (row_index >= 0 AND row_index <= 5),
+    action VARCHAR(10) NOT NULL DEFAULT 'move' CHECK (action IN ('move', 'resign', 'draw')),
+    -- Only moves have a column and row; resignations and agreed draws do not.
+    column_index INT CHECK (column_index >= 0 AND column_index <= 6),
+    row_index INT CHECK (row_index >= 0 AND row_index <= 5),
     move_number INT NOT NULL,
+    CHECK ((action = 'move') = (column_index IS NOT NULL AND row_index IS NOT NULL)),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create chat_messages table
+CREATE TABLE chat_messages (
+    id SERIAL PRIMARY KEY,
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    username VARCHAR(50) NOT NULL,
+    is_spectator BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create rating_history table
+CREATE TABLE rating_history (
+    id SERIAL PRIMARY KEY,
+    player_id INT NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    game_id UUID NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+    rating_before DOUBLE PRECISION NOT NULL,
+    rating_after DOUBLE PRECISION NOT NULL,
+    rating_deviation DOUBLE PRECISION NOT NULL,
+    rating_volatility DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create tournaments table
+CREATE TABLE tournaments (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    format VARCHAR(20) NOT NULL CHECK (format IN ('swiss', 'round-robin', 'knockout')),
+    status VARCHAR(20) NOT NULL DEFAULT 'registering' CHECK (status IN ('registering', 'running', 'finished')),
+    rounds INT NOT NULL DEFAULT 0,
+    current_round INT NOT NULL DEFAULT 0,
+    created_by INT NOT NULL REFERENCES players(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+-- Create tournament_players table
+CREATE TABLE tournament_players (
+    tournament_id INT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+    player_id INT NOT NULL REFERENCES players(id),
+    seed INT NOT NULL DEFAULT 0,
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tournament_id, player_id)
+);
+
+-- Create tournament_pairings table
+CREATE TABLE tournament_pairings (
+    id SERIAL PRIMARY KEY,
+    tournament_id INT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+    round INT NOT NULL,
+    board INT NOT NULL,
+    player1_id INT NOT NULL REFERENCES players(id),
+    player2_id INT REFERENCES players(id),
+    game_id UUID REFERENCES games(id),
+    result VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (result IN ('pending', 'player1', 'player2', 'draw', 'bye')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tournament_id, round, board)
+);
+
+-- Create sessions table
+CREATE TABLE sessions (
+    token_hash CHAR(64) PRIMARY KEY,
+    player_id INT NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -49,7 +133,14 @@ CREATE INDEX idx_games_player2 ON games(player2_id);
 CREATE INDEX idx_games_status ON games(status);
 CREATE INDEX idx_games_started_at ON games(started_at);
 CREATE INDEX idx_game_moves_game_id ON game_moves(game_id);
+CREATE INDEX idx_chat_messages_game_id ON chat_messages(game_id, created_at);
 CREATE INDEX idx_players_username ON players(username);
+CREATE INDEX idx_players_rating ON players(rating DESC);
+CREATE INDEX idx_rating_history_player ON rating_history(player_id, created_at);
+CREATE INDEX idx_tournaments_status ON tournaments(status, created_at);
+CREATE INDEX idx_tournament_pairings_game ON tournament_pairings(game_id);
+CREATE INDEX idx_sessions_player ON sessions(player_id);
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
 
 -- Create leaderboard view
 CREATE OR REPLACE VIEW leaderboard AS
@@ -62,11 +153,11 @@ SELECT
         WHEN p.games_played > 0 THEN ROUND((p.games_won::NUMERIC / p.games_played * 100), 2)
         ELSE 0 
     END as win_rate,
+    p.rating,
+    p.rating_deviation,
     p.created_at
 FROM players p
-WHERE p.games_played > 0
-ORDER BY p.games_won DESC, win_rate DESC, p.games_played DESC
-LIMIT 100;
+WHERE p.games_played > 0;
 
 -- Function to update player stats
 CREATE OR REPLACE FUNCTION update_player_stats()
@@ -96,6 +187,6 @@ $$ LANGUAGE plpgsql;
 CREATE TRIGGER trigger_update_player_stats
     AFTER UPDATE OF status ON games
     FOR EACH ROW
-    WHEN (NEW.status IN ('completed', 'forfeited', 'draw') AND OLD.status = 'active')
+    WHEN (NEW.status IN ('completed', 'forfeited', 'draw', 'timeout', 'resigned') AND OLD.status = 'active')
     EXECUTE FUNCTION update_player_stats();
 